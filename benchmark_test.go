@@ -2,6 +2,7 @@ package heap
 
 import (
 	"cmp"
+	"fmt"
 	"math/rand/v2"
 	"slices"
 	"testing"
@@ -171,6 +172,14 @@ func BenchmarkTopK(b *testing.B) {
 			}
 		}
 	})
+	b.Run("kind=Bounded", func(b *testing.B) {
+		for b.Loop() {
+			h := NewBounded(cmp.Compare[int], k)
+			for _, v := range data {
+				h.Insert(v)
+			}
+		}
+	})
 	b.Run("kind=Heap", func(b *testing.B) {
 		for b.Loop() {
 			h := New[int](cmp.Compare[int])
@@ -187,3 +196,108 @@ func BenchmarkTopK(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkArity(b *testing.B) {
+	nums := make([]int, 1000)
+	for i := range nums {
+		nums[i] = rand.Int()
+	}
+	for _, d := range []int{2, 4, 8} {
+		b.Run(fmt.Sprintf("op=Heapsort/d=%d", d), func(b *testing.B) {
+			for b.Loop() {
+				h := NewDary(cmp.Compare[int], d)
+				h.Init(slices.Clone(nums))
+				for h.Len() > 0 {
+					h.TakeMin()
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("op=Insert/d=%d", d), func(b *testing.B) {
+			for b.Loop() {
+				h := NewDary(cmp.Compare[int], d)
+				for _, n := range nums {
+					h.Insert(n)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("op=InsertHeavy/d=%d", d), func(b *testing.B) {
+			// Ten inserts for every removal.
+			for b.Loop() {
+				h := NewDary(cmp.Compare[int], d)
+				for i, n := range nums {
+					h.Insert(n)
+					if i%10 == 9 {
+						h.TakeMin()
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("op=Indexed/d=%d", d), func(b *testing.B) {
+			items := make([]*intIndexed, len(nums))
+			for i, n := range nums {
+				items[i] = &intIndexed{value: n}
+			}
+			b.ResetTimer()
+			for b.Loop() {
+				h := NewDaryIndexed(func(a, b *intIndexed) int { return cmp.Compare(a.value, b.value) },
+					d, func(v *intIndexed, i int) { v.index = i })
+				h.Init(slices.Clone(items))
+				for i := 0; i < len(items); i += 7 {
+					items[i].value ^= 1 << 20
+					h.Changed(items[i].index)
+				}
+				for h.Len() > 0 {
+					h.TakeMin()
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecreaseKey(b *testing.B) {
+	const n = 1000
+	values := make([]int, n)
+	for i := range values {
+		values[i] = rand.IntN(1 << 20)
+	}
+	decreases := make([]int, 4*n)
+	for i := range decreases {
+		decreases[i] = rand.IntN(n)
+	}
+
+	b.Run("kind=Heap", func(b *testing.B) {
+		for b.Loop() {
+			h := NewIndexed(func(a, b *intIndexed) int { return cmp.Compare(a.value, b.value) },
+				func(v *intIndexed, i int) { v.index = i })
+			items := make([]*intIndexed, n)
+			for i, v := range values {
+				items[i] = &intIndexed{value: v}
+				h.Insert(items[i])
+			}
+			for _, i := range decreases {
+				if items[i].index >= 0 {
+					items[i].value -= 100
+					h.Changed(items[i].index)
+				}
+			}
+			for h.Len() > 0 {
+				h.TakeMin()
+			}
+		}
+	})
+	b.Run("kind=Pairing", func(b *testing.B) {
+		for b.Loop() {
+			h := NewPairing(cmp.Compare[int])
+			nodes := make([]*PairingNode[int], n)
+			for i, v := range values {
+				nodes[i] = h.Insert(v)
+			}
+			for _, i := range decreases {
+				h.DecreaseKey(nodes[i], nodes[i].Value()-100)
+			}
+			for h.Len() > 0 {
+				h.TakeMin()
+			}
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package heap
+
+import (
+	"iter"
+	"slices"
+)
+
+// A Bounded holds at most k elements: the k largest of all the elements
+// inserted into it. It is built on a [Heap] of size k whose minimum is the
+// smallest element kept so far; see [Heap.ChangeMin].
+type Bounded[T any] struct {
+	h     *Heap[T]
+	k     int
+	evict func(T)
+}
+
+// NewBounded creates a new [Bounded] that keeps the k largest elements
+// according to the given comparison function.
+// See [New] for the meaning of the comparison function.
+// To keep the k smallest elements instead, reverse the comparison.
+// NewBounded panics if k is negative.
+func NewBounded[T any](compare func(T, T) int, k int) *Bounded[T] {
+	return NewBoundedEvict(compare, k, nil)
+}
+
+// NewBoundedEvict is like [NewBounded], but it also takes a function that
+// is called with each element that is rejected or evicted: an inserted
+// element that is not among the k largest, or a previously kept element
+// that is displaced by a larger one.
+func NewBoundedEvict[T any](compare func(T, T) int, k int, evict func(T)) *Bounded[T] {
+	if k < 0 {
+		panic("heap: NewBounded: negative k")
+	}
+	return &Bounded[T]{h: New(compare), k: k, evict: evict}
+}
+
+// Insert offers value to b. It reports whether value was kept,
+// which is true if value is among the k largest elements inserted so far.
+// Ties are resolved in favor of elements already kept.
+// A kept element may be evicted by a later call to Insert.
+func (b *Bounded[T]) Insert(value T) bool {
+	switch {
+	case b.h.Len() < b.k:
+		b.h.Insert(value)
+		return true
+	case b.k == 0 || b.h.compare(value, b.h.Min()) <= 0:
+		b.evicted(value)
+		return false
+	default:
+		old := b.h.Min()
+		b.h.ChangeMin(value)
+		b.evicted(old)
+		return true
+	}
+}
+
+// InsertAll calls [Bounded.Insert] on each element of the sequence.
+func (b *Bounded[T]) InsertAll(seq iter.Seq[T]) {
+	for v := range seq {
+		b.Insert(v)
+	}
+}
+
+func (b *Bounded[T]) evicted(v T) {
+	if b.evict != nil {
+		b.evict(v)
+	}
+}
+
+// K returns the maximum number of elements that b holds.
+func (b *Bounded[T]) K() int {
+	return b.k
+}
+
+// Len returns the number of elements in b, which is at most k.
+func (b *Bounded[T]) Len() int {
+	return b.h.Len()
+}
+
+// Min returns the smallest element kept. Once b is full, an inserted
+// element must be larger than Min to be kept.
+// It panics if b is empty.
+func (b *Bounded[T]) Min() T {
+	if b.h.Len() == 0 {
+		panic("heap: Min called on empty Bounded")
+	}
+	return b.h.Min()
+}
+
+// All returns an iterator over the elements in b in unspecified order.
+func (b *Bounded[T]) All() iter.Seq[T] {
+	return b.h.All()
+}
+
+// Sorted returns the elements in b in a new slice,
+// sorted from smallest to largest.
+// It does not modify b.
+func (b *Bounded[T]) Sorted() []T {
+	s := slices.Collect(b.h.All())
+	slices.SortFunc(s, b.h.compare)
+	return s
+}
+
+// Clear removes all elements from b without calling the eviction function.
+func (b *Bounded[T]) Clear() {
+	b.h.Clear()
+}
@@ -0,0 +1,72 @@
+package heap
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestBounded(t *testing.T) {
+	for _, k := range []int{0, 1, 5, 100} {
+		var evicted []int
+		b := NewBoundedEvict(cmp.Compare[int], k, func(v int) { evicted = append(evicted, v) })
+		data := make([]int, 50)
+		for i := range data {
+			data[i] = rand.IntN(30)
+		}
+		kept := 0
+		for _, v := range data {
+			if b.Insert(v) {
+				kept++
+			}
+		}
+
+		want := slices.Sorted(slices.Values(data))
+		want = want[max(0, len(want)-k):]
+		if got := b.Sorted(); !slices.Equal(got, want) {
+			t.Errorf("k=%d: Sorted() = %v, want %v", k, got, want)
+		}
+		if b.Len() != len(want) {
+			t.Errorf("k=%d: Len() = %d, want %d", k, b.Len(), len(want))
+		}
+		// Every inserted element was either kept or evicted exactly once.
+		if len(evicted)+b.Len() != len(data) {
+			t.Errorf("k=%d: %d evicted + %d kept != %d inserted", k, len(evicted), b.Len(), len(data))
+		}
+		if got, want := kept-b.Len(), len(evicted)-(len(data)-kept); got != want {
+			t.Errorf("k=%d: kept and later evicted: %d, want %d", k, got, want)
+		}
+	}
+}
+
+func TestBoundedInsert(t *testing.T) {
+	var evicted []int
+	b := NewBoundedEvict(cmp.Compare[int], 2, func(v int) { evicted = append(evicted, v) })
+	for _, test := range []struct {
+		v       int
+		kept    bool
+		evicted []int
+	}{
+		{5, true, nil},
+		{3, true, nil},
+		{1, false, []int{1}},
+		{3, false, []int{3}}, // ties favor kept elements
+		{4, true, []int{3}},
+		{9, true, []int{4}},
+	} {
+		evicted = nil
+		if got := b.Insert(test.v); got != test.kept {
+			t.Errorf("Insert(%d) = %t, want %t", test.v, got, test.kept)
+		}
+		if !slices.Equal(evicted, test.evicted) {
+			t.Errorf("Insert(%d): evicted %v, want %v", test.v, evicted, test.evicted)
+		}
+	}
+	if got, want := b.Sorted(), []int{5, 9}; !slices.Equal(got, want) {
+		t.Errorf("Sorted() = %v, want %v", got, want)
+	}
+	if got := b.Min(); got != 5 {
+		t.Errorf("Min() = %d, want 5", got)
+	}
+}
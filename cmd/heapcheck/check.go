@@ -0,0 +1,532 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+)
+
+const heapPath = "github.com/jba/heap"
+
+// unindexed lists the constructors that do not take an index function.
+// Delete and Changed on the heaps they create panic for any index but 0.
+// Each has a counterpart whose name ends in "Indexed".
+var unindexed = map[string]bool{
+	"New":       true,
+	"NewDary":   true,
+	"NewMinMax": true,
+	"NewStable": true,
+}
+
+// readOnly lists the methods that do not change a heap.
+var readOnly = map[string]bool{
+	"All": true, "Cap": true, "Clone": true, "CloneIndexed": true,
+	"Contains": true, "K": true, "Len": true, "Max": true, "Min": true,
+	"MinID": true, "Priority": true, "Smallest": true, "Snapshot": true,
+	"Sorted": true, "Value": true, "Verify": true, "VerifyIndexed": true,
+}
+
+// notify lists the methods and functions that restore the heap property
+// after an element has changed, or that add the element to a heap or remove
+// it. The functions are those of container/heap and the compat package.
+var notify = map[string]bool{
+	"ChangeMin": true, "Changed": true, "DecreaseKey": true, "Delete": true,
+	"Fix": true, "IncreaseKey": true, "Init": true, "Insert": true,
+	"InsertAll": true, "Push": true, "Remove": true, "Set": true, "Update": true,
+}
+
+// A checker finds misuses of the heap package in a type-checked package.
+type checker struct {
+	fset    *token.FileSet
+	files   []*ast.File
+	pkg     *types.Package
+	info    *types.Info
+	parents map[ast.Node]ast.Node
+	funcs   map[*types.Func]*ast.FuncDecl
+	diags   []diagnostic
+}
+
+// A diagnostic reports a misuse.
+type diagnostic struct {
+	pos token.Position
+	msg string
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.pos, d.msg)
+}
+
+// check returns the misuses of the heap package in files.
+func check(fset *token.FileSet, files []*ast.File, pkg *types.Package, info *types.Info) []diagnostic {
+	c := &checker{
+		fset:    fset,
+		files:   files,
+		pkg:     pkg,
+		info:    info,
+		parents: map[ast.Node]ast.Node{},
+		funcs:   map[*types.Func]*ast.FuncDecl{},
+	}
+	for _, f := range files {
+		var stack []ast.Node
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return false
+			}
+			if len(stack) > 0 {
+				c.parents[n] = stack[len(stack)-1]
+			}
+			stack = append(stack, n)
+			if fd, ok := n.(*ast.FuncDecl); ok {
+				if fn, ok := info.Defs[fd.Name].(*types.Func); ok {
+					c.funcs[fn] = fd
+				}
+			}
+			return true
+		})
+	}
+	c.checkIndexes()
+	c.checkDrain()
+	c.checkCompareFields()
+	c.checkInit()
+	slices.SortFunc(c.diags, func(a, b diagnostic) int {
+		if a.pos.Filename != b.pos.Filename {
+			if a.pos.Filename < b.pos.Filename {
+				return -1
+			}
+			return 1
+		}
+		return a.pos.Offset - b.pos.Offset
+	})
+	return c.diags
+}
+
+func (c *checker) report(pos token.Pos, format string, args ...any) {
+	c.diags = append(c.diags, diagnostic{c.fset.Position(pos), fmt.Sprintf(format, args...)})
+}
+
+// heapFunc returns the function of the heap package called by call,
+// or nil.
+func (c *checker) heapFunc(call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	case *ast.IndexExpr: // explicit instantiation
+		return c.heapFunc(&ast.CallExpr{Fun: fun.X})
+	case *ast.IndexListExpr:
+		return c.heapFunc(&ast.CallExpr{Fun: fun.X})
+	default:
+		return nil
+	}
+	fn, ok := c.info.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != heapPath {
+		return nil
+	}
+	return fn
+}
+
+// heapMethod returns the receiver and name of the method of a heap package
+// type called by call. It returns a nil receiver if call does not call such
+// a method.
+func (c *checker) heapMethod(call *ast.CallExpr) (ast.Expr, string) {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return nil, ""
+	}
+	s := c.info.Selections[sel]
+	if s == nil || s.Kind() != types.MethodVal {
+		return nil, ""
+	}
+	fn := s.Obj().(*types.Func)
+	if fn.Pkg() == nil || fn.Pkg().Path() != heapPath {
+		return nil, ""
+	}
+	return sel.X, fn.Name()
+}
+
+// object returns the variable or field that e denotes, or nil.
+func (c *checker) object(e ast.Expr) types.Object {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		if obj, ok := c.info.Uses[e].(*types.Var); ok {
+			return obj
+		}
+		if obj, ok := c.info.Defs[e].(*types.Var); ok {
+			return obj
+		}
+	case *ast.SelectorExpr:
+		if s := c.info.Selections[e]; s != nil && s.Kind() == types.FieldVal {
+			return s.Obj()
+		}
+	}
+	return nil
+}
+
+// sameHeap reports whether x and y denote the same heap.
+func (c *checker) sameHeap(x, y ast.Expr) bool {
+	ox, oy := c.object(x), c.object(y)
+	return ox != nil && ox == oy && types.ExprString(ast.Unparen(x)) == types.ExprString(ast.Unparen(y))
+}
+
+// checkIndexes reports calls to Delete or Changed with an index other than
+// 0 on a heap that was created without an index function.
+func (c *checker) checkIndexes() {
+	// ctors maps each variable or field to the unindexed constructor that
+	// creates every value assigned to it, or to "" if any other value is
+	// assigned to it.
+	ctors := map[types.Object]string{}
+	assign := func(lhs types.Object, rhs ast.Expr) {
+		if lhs == nil {
+			return
+		}
+		name := ""
+		if call, ok := ast.Unparen(rhs).(*ast.CallExpr); ok {
+			if fn := c.heapFunc(call); fn != nil && unindexed[fn.Name()] {
+				name = fn.Name()
+			}
+		}
+		if prev, ok := ctors[lhs]; ok && prev != name {
+			name = ""
+		}
+		ctors[lhs] = name
+	}
+	for _, f := range c.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					var rhs ast.Expr
+					if len(n.Lhs) == len(n.Rhs) {
+						rhs = n.Rhs[i]
+					}
+					assign(c.object(lhs), rhs)
+				}
+			case *ast.ValueSpec:
+				for i, name := range n.Names {
+					if i < len(n.Values) && len(n.Names) == len(n.Values) {
+						assign(c.info.Defs[name], n.Values[i])
+					} else if len(n.Values) > 0 {
+						assign(c.info.Defs[name], nil)
+					}
+				}
+			case *ast.CompositeLit:
+				t := c.info.TypeOf(n)
+				if t == nil {
+					return true
+				}
+				st, ok := t.Underlying().(*types.Struct)
+				if !ok {
+					return true
+				}
+				for i, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if id, ok := kv.Key.(*ast.Ident); ok {
+							assign(c.info.Uses[id], kv.Value)
+						}
+					} else if i < st.NumFields() {
+						assign(st.Field(i), elt)
+					}
+				}
+			case *ast.UnaryExpr:
+				// The variable may be assigned through the pointer.
+				if n.Op == token.AND {
+					assign(c.object(n.X), nil)
+				}
+			}
+			return true
+		})
+	}
+	c.inspectCalls(func(call *ast.CallExpr) {
+		recv, name := c.heapMethod(call)
+		if recv == nil || (name != "Delete" && name != "Changed") || len(call.Args) != 1 {
+			return
+		}
+		ctor := ctors[c.object(recv)]
+		if ctor == "" {
+			return
+		}
+		if tv := c.info.Types[call.Args[0]]; tv.Value != nil && constant.Sign(tv.Value) == 0 {
+			return
+		}
+		c.report(call.Pos(), "%s.%s called with an index other than 0 on a heap created by %s, which panics; create the heap with %sIndexed",
+			types.ExprString(recv), name, ctor, ctor)
+	})
+}
+
+// checkDrain reports changes to a heap in the body of a loop that drains it.
+func (c *checker) checkDrain() {
+	for _, f := range c.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			rs, ok := n.(*ast.RangeStmt)
+			if !ok {
+				return true
+			}
+			call, ok := ast.Unparen(rs.X).(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			recv, name := c.heapMethod(call)
+			if recv == nil || (name != "Drain" && name != "DrainMax") {
+				return true
+			}
+			ast.Inspect(rs.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.FuncLit:
+					return false
+				case *ast.CallExpr:
+					r, m := c.heapMethod(n)
+					if r != nil && !readOnly[m] && c.sameHeap(r, recv) {
+						c.report(n.Pos(), "%s.%s changes %[1]s while it is being drained", types.ExprString(r), m)
+					}
+				}
+				return true
+			})
+			return true
+		})
+	}
+}
+
+// checkCompareFields reports assignments to fields that a heap's comparison
+// function uses, when no call that restores the heap property follows.
+func (c *checker) checkCompareFields() {
+	fields := map[types.Object]bool{}
+	compares := map[ast.Node]bool{}
+	c.inspectCalls(func(call *ast.CallExpr) {
+		fn := c.heapFunc(call)
+		if fn == nil {
+			return
+		}
+		params := fn.Type().(*types.Signature).Params()
+		for i := range params.Len() {
+			if params.At(i).Name() != "compare" || i >= len(call.Args) {
+				continue
+			}
+			var body ast.Node
+			switch arg := ast.Unparen(call.Args[i]).(type) {
+			case *ast.FuncLit:
+				body = arg
+			case *ast.Ident:
+				if fn, ok := c.info.Uses[arg].(*types.Func); ok && c.funcs[fn] != nil {
+					body = c.funcs[fn]
+				}
+			}
+			if body == nil {
+				continue
+			}
+			compares[body] = true
+			ast.Inspect(body, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok {
+					if s := c.info.Selections[sel]; s != nil && s.Kind() == types.FieldVal {
+						fields[s.Obj()] = true
+					}
+				}
+				return true
+			})
+		}
+	})
+	if len(fields) == 0 {
+		return
+	}
+	for _, f := range c.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if compares[n] {
+				return false
+			}
+			var lhs []ast.Expr
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if n.Tok != token.DEFINE {
+					lhs = n.Lhs
+				}
+			case *ast.IncDecStmt:
+				lhs = []ast.Expr{n.X}
+			default:
+				return true
+			}
+			for _, e := range lhs {
+				sel, ok := ast.Unparen(e).(*ast.SelectorExpr)
+				if !ok || !fields[c.object(sel)] || c.fresh(sel.X) || c.notified(n) {
+					continue
+				}
+				c.report(sel.Pos(), "%s is used by the comparison function of a heap, but its assignment is not followed by a call to Changed",
+					types.ExprString(sel))
+			}
+			return true
+		})
+	}
+}
+
+// fresh reports whether e is a local variable initialized with a new value,
+// which cannot yet be in a heap.
+func (c *checker) fresh(e ast.Expr) bool {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	obj := c.info.Uses[id]
+	if obj == nil {
+		return false
+	}
+	for def, o := range c.info.Defs {
+		if o != obj {
+			continue
+		}
+		var val ast.Expr
+		switch p := c.parents[def].(type) {
+		case *ast.AssignStmt:
+			if p.Tok == token.DEFINE && len(p.Lhs) == len(p.Rhs) {
+				val = p.Rhs[slices.Index(p.Lhs, ast.Expr(def))]
+			}
+		case *ast.ValueSpec:
+			if len(p.Values) == 0 {
+				return true // the zero value
+			}
+			if len(p.Names) == len(p.Values) {
+				val = p.Values[slices.Index(p.Names, def)]
+			}
+		}
+		switch v := ast.Unparen(val).(type) {
+		case *ast.CompositeLit:
+			return true
+		case *ast.UnaryExpr:
+			_, ok := ast.Unparen(v.X).(*ast.CompositeLit)
+			return v.Op == token.AND && ok
+		case *ast.CallExpr:
+			id, ok := ast.Unparen(v.Fun).(*ast.Ident)
+			if ok {
+				b, ok := c.info.Uses[id].(*types.Builtin)
+				return ok && b.Name() == "new"
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// notified reports whether a call to a heap method that restores the heap
+// property, or adds or removes an element, follows the statement s in its
+// function.
+func (c *checker) notified(s ast.Node) bool {
+	for n := s; n != nil; n = c.parents[n] {
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return false
+		}
+		list := stmtList(c.parents[n])
+		i := slices.IndexFunc(list, func(st ast.Stmt) bool { return st == n })
+		if i < 0 {
+			continue
+		}
+		found := false
+		for _, st := range list[i+1:] {
+			ast.Inspect(st, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok && c.notifies(call) {
+					found = true
+				}
+				return !found
+			})
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// notifies reports whether call calls a method or function in notify.
+func (c *checker) notifies(call *ast.CallExpr) bool {
+	if recv, name := c.heapMethod(call); recv != nil {
+		return notify[name]
+	}
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := c.info.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return false
+	}
+	switch fn.Pkg().Path() {
+	case "container/heap", heapPath + "/compat":
+		return notify[fn.Name()]
+	}
+	return false
+}
+
+// checkInit reports uses of a slice after it has been passed to the Init
+// method of a heap, which takes ownership of it.
+func (c *checker) checkInit() {
+	c.inspectCalls(func(call *ast.CallExpr) {
+		recv, name := c.heapMethod(call)
+		if recv == nil || name != "Init" || len(call.Args) != 1 {
+			return
+		}
+		id, ok := ast.Unparen(call.Args[0]).(*ast.Ident)
+		if !ok {
+			return
+		}
+		v, ok := c.info.Uses[id].(*types.Var)
+		if !ok || v.Parent() == c.pkg.Scope() {
+			return
+		}
+		var body ast.Node
+		for n := ast.Node(call); n != nil && body == nil; n = c.parents[n] {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				body = n.Body
+			case *ast.FuncLit:
+				body = n.Body
+			}
+		}
+		if body == nil {
+			return
+		}
+		done := false
+		ast.Inspect(body, func(n ast.Node) bool {
+			if done {
+				return false
+			}
+			use, ok := n.(*ast.Ident)
+			if !ok || use.Pos() < call.End() || c.info.Uses[use] != v {
+				return true
+			}
+			done = true
+			if as, ok := c.parents[use].(*ast.AssignStmt); ok && as.Tok == token.ASSIGN && slices.Contains(as.Lhs, ast.Expr(use)) {
+				return false // a new value
+			}
+			c.report(use.Pos(), "%s is used after being passed to %s.Init, which takes ownership of it", use.Name, types.ExprString(recv))
+			return false
+		})
+	})
+}
+
+// inspectCalls calls f on each call expression in the package.
+func (c *checker) inspectCalls(f func(*ast.CallExpr)) {
+	for _, file := range c.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				f(call)
+			}
+			return true
+		})
+	}
+}
+
+// stmtList returns the statements of a block, case clause or select clause.
+func stmtList(n ast.Node) []ast.Stmt {
+	switch n := n.(type) {
+	case *ast.BlockStmt:
+		return n.List
+	case *ast.CaseClause:
+		return n.Body
+	case *ast.CommClause:
+		return n.Body
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCheck checks each file in testdata. A comment of the form
+//
+//	// want "regexp"
+//
+// on a line means that heapcheck should report a diagnostic on that line
+// matching the regexp.
+func TestCheck(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := &types.Info{
+				Types:      map[ast.Expr]types.TypeAndValue{},
+				Defs:       map[*ast.Ident]types.Object{},
+				Uses:       map[*ast.Ident]types.Object{},
+				Selections: map[*ast.SelectorExpr]*types.Selection{},
+			}
+			conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+			pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDiagnostics(t, file, check(fset, []*ast.File{f}, pkg, info))
+		})
+	}
+}
+
+var wantRegexp = regexp.MustCompile(`// want (".*")$`)
+
+func checkDiagnostics(t *testing.T, file string, diags []diagnostic) {
+	t.Helper()
+	src, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wants := map[int]*regexp.Regexp{}
+	for i, line := range strings.Split(string(src), "\n") {
+		m := wantRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		pat, err := strconv.Unquote(m[1])
+		if err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		wants[i+1] = regexp.MustCompile(pat)
+	}
+	for _, d := range diags {
+		re := wants[d.pos.Line]
+		if re == nil || !re.MatchString(d.msg) {
+			t.Errorf("unexpected diagnostic: %s", d)
+			continue
+		}
+		delete(wants, d.pos.Line)
+	}
+	for line, re := range wants {
+		t.Errorf("line %d: no diagnostic matching %q", line, re)
+	}
+}
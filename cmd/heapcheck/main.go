@@ -0,0 +1,227 @@
+// Heapcheck reports common misuses of github.com/jba/heap.
+//
+// Usage:
+//
+//	heapcheck [packages]
+//	go vet -vettool=$(which heapcheck) [packages]
+//
+// Run directly, heapcheck runs go vet with itself as the vet tool, so the
+// two forms are equivalent. Packages are named as for the go command.
+//
+// Heapcheck reports:
+//
+//   - calls to Delete or Changed with an index other than 0 on a heap
+//     created by [heap.New], [heap.NewDary], [heap.NewMinMax] or
+//     [heap.NewStable]. Those heaps have no index function, so the calls
+//     panic. Use the corresponding constructor whose name ends in Indexed.
+//   - calls that change a heap in the body of a loop over the heap's Drain
+//     or DrainMax method, whose result is then undefined.
+//   - assignments to a field that a heap's comparison function reads, when
+//     no call to Changed (or another method that adds, removes or fixes an
+//     element) follows in the same function. Assignments to a local variable
+//     that holds a newly created value are not reported.
+//   - uses of a slice after it has been passed to the Init method of a
+//     [heap.Heap] or [heap.MinMaxHeap], which takes ownership of it.
+//
+// Heapcheck only knows what a single package tells it, so it reports a
+// missing index function only for variables and fields that are assigned in
+// the package being checked.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	jsonFlag    = flag.Bool("json", false, "emit diagnostics as JSON")
+	versionFlag = flag.String("V", "", "print version and exit")
+	flagsFlag   = flag.Bool("flags", false, "print the tool's flags as JSON and exit")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: heapcheck [packages]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	switch {
+	case *flagsFlag:
+		// go vet asks which flags the tool accepts.
+		fmt.Println(`[{"Name":"json","Bool":true,"Usage":"emit diagnostics as JSON"}]`)
+	case *versionFlag != "":
+		printVersion()
+	case flag.NArg() == 1 && strings.HasSuffix(flag.Arg(0), ".cfg"):
+		n, err := runUnit(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "heapcheck: %v\n", err)
+			os.Exit(1)
+		}
+		if n > 0 && !*jsonFlag {
+			os.Exit(1)
+		}
+	default:
+		exe, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "heapcheck: %v\n", err)
+			os.Exit(1)
+		}
+		args := []string{"vet", "-vettool=" + exe}
+		if *jsonFlag {
+			args = append(args, "-json")
+		}
+		cmd := exec.Command("go", append(args, flag.Args()...)...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			if ee, ok := err.(*exec.ExitError); ok {
+				os.Exit(ee.ExitCode())
+			}
+			fmt.Fprintf(os.Stderr, "heapcheck: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// printVersion prints the version line that go vet uses to cache results.
+// It identifies the tool by the hash of its executable.
+func printVersion() {
+	name := filepath.Base(os.Args[0])
+	h := sha256.New()
+	if exe, err := os.Executable(); err == nil {
+		if f, err := os.Open(exe); err == nil {
+			io.Copy(h, f)
+			f.Close()
+		}
+	}
+	fmt.Printf("%s version devel buildID=%02x\n", name, h.Sum(nil))
+}
+
+// A config describes a package to check. The go command writes it to a
+// file and passes its name to the vet tool.
+type config struct {
+	ID                        string // package ID, for JSON output
+	ImportPath                string
+	GoFiles                   []string
+	ImportMap                 map[string]string // import path to package path
+	PackageFile               map[string]string // package path to export data file
+	VetxOnly                  bool              // only compute facts, which heapcheck has none of
+	VetxOutput                string            // where to write facts
+	Stdout                    string            // where to write JSON output, if not standard output
+	SucceedOnTypecheckFailure bool
+}
+
+// runUnit checks the package described by the config file, prints the
+// diagnostics and returns their number.
+func runUnit(filename string) (int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	var cfg config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return 0, fmt.Errorf("%s: %v", filename, err)
+	}
+	// The go command expects the facts file even though it is empty.
+	if cfg.VetxOutput != "" {
+		if err := os.WriteFile(cfg.VetxOutput, nil, 0666); err != nil {
+			return 0, err
+		}
+	}
+	if cfg.VetxOnly {
+		return 0, nil
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range cfg.GoFiles {
+		f, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			if cfg.SucceedOnTypecheckFailure {
+				return 0, nil
+			}
+			return 0, err
+		}
+		files = append(files, f)
+	}
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := cfg.PackageFile[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %q", path)
+		}
+		return os.Open(file)
+	})
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if p, ok := cfg.ImportMap[path]; ok {
+				path = p
+			}
+			return imp.Import(path)
+		}),
+	}
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	pkg, err := conf.Check(cfg.ImportPath, fset, files, info)
+	if err != nil {
+		if cfg.SucceedOnTypecheckFailure {
+			return 0, nil
+		}
+		if *jsonFlag {
+			return 0, writeJSON(cfg, map[string]string{"error": err.Error()})
+		}
+		return 0, err
+	}
+	diags := check(fset, files, pkg, info)
+	if *jsonFlag {
+		if len(diags) == 0 {
+			return 0, nil
+		}
+		type jsonDiagnostic struct {
+			Posn    string `json:"posn"`
+			Message string `json:"message"`
+		}
+		var jds []jsonDiagnostic
+		for _, d := range diags {
+			jds = append(jds, jsonDiagnostic{d.pos.String(), d.msg})
+		}
+		return len(diags), writeJSON(cfg, jds)
+	}
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	return len(diags), nil
+}
+
+// writeJSON writes the result for the package in the form that go vet
+// expects: a map from package ID to a map from analyzer name to result.
+func writeJSON(cfg config, result any) error {
+	data, err := json.MarshalIndent(map[string]map[string]any{cfg.ID: {"heapcheck": result}}, "", "\t")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if cfg.Stdout != "" {
+		return os.WriteFile(cfg.Stdout, data, 0666)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
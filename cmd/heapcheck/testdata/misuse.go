@@ -0,0 +1,109 @@
+package misuse
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/jba/heap"
+	"github.com/jba/heap/compat"
+)
+
+type item struct {
+	name     string
+	priority int
+	index    int
+}
+
+func compareItems(a, b *item) int { return cmp.Compare(a.priority, b.priority) }
+
+func noIndex(it *item, i int) {
+	h := heap.New(compareItems)
+	h.Insert(it)
+	h.Changed(0)
+	h.Delete(it.index) // want "h.Delete called with an index other than 0 on a heap created by New"
+	h.Changed(i)       // want "h.Changed called with an index other than 0 on a heap created by New"
+}
+
+type server struct {
+	queue *heap.Heap[*item]
+}
+
+func newServer() *server {
+	return &server{queue: heap.NewDary(compareItems, 4)}
+}
+
+func (s *server) cancel(it *item) {
+	s.queue.Delete(it.index) // want "s.queue.Delete called with an index other than 0 on a heap created by NewDary, which panics; create the heap with NewDaryIndexed"
+}
+
+func indexed(it *item) {
+	h := heap.NewIndexed(compareItems, func(it *item, i int) { it.index = i })
+	h.Insert(it)
+	it.priority = 3
+	h.Changed(it.index)
+	h.Delete(it.index)
+}
+
+func param(h *heap.Heap[*item], it *item) {
+	h.Delete(it.index) // unknown how h was created
+}
+
+func drain(h *heap.Heap[*item]) {
+	for it := range h.Drain() {
+		fmt.Println(it.name, h.Len())
+		if it.priority > 1 {
+			it.priority--
+			h.Insert(it) // want "h.Insert changes h while it is being drained"
+		}
+	}
+}
+
+func update(h *heap.Heap[*item], it *item) {
+	it.priority = 7 // want "it.priority is used by the comparison function of a heap, but its assignment is not followed by a call to Changed"
+	it.name = "x"
+	fmt.Println(it.index)
+}
+
+func updateAndFix(h *heap.Heap[*item], it *item) {
+	it.priority++
+	if it.priority > 10 {
+		fmt.Println("big")
+	}
+	h.Changed(it.index)
+}
+
+func updateWithCompat(h *heap.Heap[*item], it *item) {
+	it.priority = 2
+	compat.Fix(h, it.index)
+}
+
+func build(h *heap.Heap[*item]) *item {
+	it := &item{name: "new"}
+	it.priority = 4
+	return it
+}
+
+type point struct{ x, y float64 }
+
+func byX() *heap.Heap[point] {
+	return heap.New(func(a, b point) int { return cmp.Compare(a.x, b.x) })
+}
+
+func move(ps []point) {
+	ps[0].x = 1 // want "ps\\[0\\].x is used by the comparison function"
+	ps[0].y = 2
+}
+
+func owned(s []*item) {
+	h := heap.New(compareItems)
+	h.Init(s)
+	fmt.Println(h.Len())
+	fmt.Println(len(s)) // want "s is used after being passed to h.Init, which takes ownership of it"
+}
+
+func reused(s []*item) {
+	h := heap.New(compareItems)
+	h.Init(s)
+	s = nil
+	fmt.Println(s, h.Len())
+}
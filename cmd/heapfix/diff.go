@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// unifiedDiff returns a unified diff from old to new, with three lines of
+// context, in the form printed by gofmt -d.
+func unifiedDiff(name string, old, new []byte) []byte {
+	x, y := lines(old), lines(new)
+
+	// lcs[i][j] is the length of the longest common subsequence
+	// of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// An op is a line of the diff. Lines i of x and j of y follow it.
+	type op struct {
+		kind byte // ' ', '-' or '+'
+		text string
+		i, j int
+	}
+	var ops []op
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			i, j = i+1, j+1
+			ops = append(ops, op{' ', x[i-1], i, j})
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			i++
+			ops = append(ops, op{'-', x[i-1], i, j})
+		default:
+			j++
+			ops = append(ops, op{'+', y[j-1], i, j})
+		}
+	}
+
+	const context = 3
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "diff -u %[1]s.orig %[1]s\n--- %[1]s.orig\n+++ %[1]s\n", name)
+	for start := 0; start < len(ops); {
+		// Find the next change, and the end of the hunk that contains it.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		end := first
+		for k := first; k < len(ops) && k-end <= 2*context; k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			}
+		}
+		lo, hi := max(start, first-context), min(len(ops), end+context)
+		var nx, ny int
+		for _, o := range ops[lo:hi] {
+			if o.kind != '+' {
+				nx++
+			}
+			if o.kind != '-' {
+				ny++
+			}
+		}
+		// The first line of the hunk in each file, counting from 1,
+		// or the line before the hunk if it is empty there.
+		fx, fy := ops[lo].i, ops[lo].j
+		if ops[lo].kind != '+' {
+			fx--
+		}
+		if ops[lo].kind != '-' {
+			fy--
+		}
+		if nx > 0 {
+			fx++
+		}
+		if ny > 0 {
+			fy++
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", fx, nx, fy, ny)
+		for _, o := range ops[lo:hi] {
+			b.WriteByte(o.kind)
+			b.WriteString(o.text)
+			b.WriteByte('\n')
+		}
+		start = hi
+	}
+	return b.Bytes()
+}
+
+func lines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
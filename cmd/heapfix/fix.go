@@ -0,0 +1,1141 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	stdHeapPath = "container/heap"
+	heapPath    = "github.com/jba/heap"
+)
+
+// A pass holds the state of heapfix for a single package.
+type pass struct {
+	fset    *token.FileSet
+	files   []*ast.File
+	names   map[*ast.File]string
+	src     map[*token.File][]byte
+	pkg     *types.Package
+	info    *types.Info
+	parents map[ast.Node]ast.Node
+	types   map[*types.TypeName]*heapType
+	diags   []diagnostic
+}
+
+// A diagnostic reports a problem that prevents a rewrite.
+type diagnostic struct {
+	pos token.Position
+	msg string
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.pos, d.msg)
+}
+
+// An edit replaces the source text between pos and end.
+type edit struct {
+	pos, end token.Pos
+	text     string
+}
+
+// A heapType describes a type that implements container/heap.Interface.
+//
+// The key and index fields are templates: source text in which each NUL byte
+// stands for an element of the heap.
+type heapType struct {
+	name     *types.TypeName
+	elem     types.Type
+	key      string          // elements are ordered by key; "" if Less is too complex
+	reversed bool            // Less(i, j) reports whether key(j) < key(i)
+	index    string          // field maintained by Swap; "" if there is none
+	idents   map[string]bool // identifiers that appear in key or index
+	err      error           // why the type cannot be rewritten
+}
+
+// A result is the outcome of running heapfix on a package.
+type result struct {
+	diags   []diagnostic
+	changed map[string][]byte // new contents of rewritten files, by file name
+}
+
+// fix runs heapfix on the named files, which must make up a single package.
+func fix(filenames []string) (*result, error) {
+	p := &pass{
+		fset:    token.NewFileSet(),
+		names:   map[*ast.File]string{},
+		src:     map[*token.File][]byte{},
+		parents: map[ast.Node]ast.Node{},
+		types:   map[*types.TypeName]*heapType{},
+	}
+	for _, name := range filenames {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(p.fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.files = append(p.files, f)
+		p.names[f] = name
+		p.src[p.fset.File(f.Pos())] = src
+	}
+	p.info = &types.Info{
+		Types:     map[ast.Expr]types.TypeAndValue{},
+		Defs:      map[*ast.Ident]types.Object{},
+		Uses:      map[*ast.Ident]types.Object{},
+		Implicits: map[ast.Node]types.Object{},
+	}
+	// Type errors are ignored: heapfix only rewrites code whose types it
+	// understands, and the rewritten files are reparsed before they are
+	// written.
+	conf := types.Config{Importer: importer.Default(), Error: func(error) {}}
+	p.pkg, _ = conf.Check(p.files[0].Name.Name, p.fset, p.files, p.info)
+	for _, f := range p.files {
+		var stack []ast.Node
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return false
+			}
+			if len(stack) > 0 {
+				p.parents[n] = stack[len(stack)-1]
+			}
+			stack = append(stack, n)
+			return true
+		})
+	}
+
+	p.findHeapTypes()
+	res := &result{changed: map[string][]byte{}}
+	for _, f := range p.files {
+		src, err := p.fixFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if src != nil {
+			res.changed[p.names[f]] = src
+		}
+	}
+	slices.SortFunc(p.diags, func(a, b diagnostic) int {
+		if c := strings.Compare(a.pos.Filename, b.pos.Filename); c != 0 {
+			return c
+		}
+		return a.pos.Offset - b.pos.Offset
+	})
+	res.diags = p.diags
+	return res, nil
+}
+
+func (p *pass) report(pos token.Pos, format string, args ...any) {
+	p.diags = append(p.diags, diagnostic{p.fset.Position(pos), fmt.Sprintf(format, args...)})
+}
+
+// text returns the source text of n.
+func (p *pass) text(n ast.Node) string {
+	tf := p.fset.File(n.Pos())
+	return string(p.src[tf][tf.Offset(n.Pos()):tf.Offset(n.End())])
+}
+
+func (p *pass) line(n ast.Node) int {
+	return p.fset.Position(n.Pos()).Line
+}
+
+// parent returns the parent of n, skipping parentheses.
+func (p *pass) parent(n ast.Node) ast.Node {
+	par := p.parents[n]
+	for {
+		pe, ok := par.(*ast.ParenExpr)
+		if !ok {
+			return par
+		}
+		par = p.parents[pe]
+	}
+}
+
+// findHeapTypes finds the types in the package that implement
+// container/heap.Interface, and analyzes their methods.
+func (p *pass) findHeapTypes() {
+	methods := map[*types.TypeName]map[string]*ast.FuncDecl{}
+	for _, f := range p.files {
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Recv == nil || len(fd.Recv.List) != 1 || fd.Body == nil {
+				continue
+			}
+			rt := ast.Unparen(fd.Recv.List[0].Type)
+			if se, ok := rt.(*ast.StarExpr); ok {
+				rt = ast.Unparen(se.X)
+			}
+			id, ok := rt.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			tn, ok := p.info.Uses[id].(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if methods[tn] == nil {
+				methods[tn] = map[string]*ast.FuncDecl{}
+			}
+			methods[tn][fd.Name.Name] = fd
+		}
+	}
+	for tn, ms := range methods {
+		if ms["Len"] != nil && ms["Less"] != nil && ms["Swap"] != nil && ms["Push"] != nil && ms["Pop"] != nil {
+			p.types[tn] = p.analyzeType(tn, ms)
+		}
+	}
+}
+
+func (p *pass) analyzeType(tn *types.TypeName, ms map[string]*ast.FuncDecl) *heapType {
+	ht := &heapType{name: tn, idents: map[string]bool{}}
+	s, ok := tn.Type().Underlying().(*types.Slice)
+	if !ok {
+		ht.err = fmt.Errorf("%s is not a slice type", tn.Name())
+		return ht
+	}
+	ht.elem = s.Elem()
+	p.analyzeLess(ht, ms["Less"])
+	if ht.err = p.analyzeSwap(ht, ms["Swap"]); ht.err != nil {
+		return ht
+	}
+	for _, m := range []string{"Push", "Pop"} {
+		if ht.err = p.checkSideEffects(ms[m]); ht.err != nil {
+			return ht
+		}
+	}
+	return ht
+}
+
+// analyzeLess looks for a Less method of the form
+//
+//	return h[i].key < h[j].key
+//
+// and records the key. If Less has another form, the comparison function
+// calls Less itself.
+func (p *pass) analyzeLess(ht *heapType, fd *ast.FuncDecl) {
+	recv, params := p.funcObjs(fd)
+	if recv == nil || len(params) != 2 || len(fd.Body.List) != 1 {
+		return
+	}
+	ret, ok := fd.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return
+	}
+	be, ok := ast.Unparen(ret.Results[0]).(*ast.BinaryExpr)
+	if !ok || (be.Op != token.LSS && be.Op != token.GTR) {
+		return
+	}
+	for _, swapped := range []bool{false, true} {
+		i, j := params[0], params[1]
+		if swapped {
+			i, j = j, i
+		}
+		x, ok1 := p.template(be.X, recv, i, j)
+		y, ok2 := p.template(be.Y, recv, j, i)
+		if ok1 && ok2 && x == y && strings.Contains(x, "\x00") {
+			ht.key = x
+			ht.reversed = (be.Op == token.GTR) != swapped
+			p.addIdents(ht, be, recv, i, j)
+			return
+		}
+	}
+}
+
+// analyzeSwap checks that a Swap method exchanges two elements and
+// optionally records their new positions in an index field:
+//
+//	h[i], h[j] = h[j], h[i]
+//	h[i].index = i
+//	h[j].index = j
+func (p *pass) analyzeSwap(ht *heapType, fd *ast.FuncDecl) error {
+	errSwap := fmt.Errorf("%s.Swap does more than exchange elements and record their indexes", ht.name.Name())
+	recv, params := p.funcObjs(fd)
+	if recv == nil || len(params) != 2 {
+		return errSwap
+	}
+	var (
+		swapped bool
+		index   string
+		set     [2]bool
+	)
+	for _, s := range fd.Body.List {
+		as, ok := s.(*ast.AssignStmt)
+		if !ok || as.Tok != token.ASSIGN || len(as.Lhs) != len(as.Rhs) {
+			return errSwap
+		}
+		switch len(as.Lhs) {
+		case 2:
+			l0, l1 := p.elemParam(as.Lhs[0], recv, params), p.elemParam(as.Lhs[1], recv, params)
+			r0, r1 := p.elemParam(as.Rhs[0], recv, params), p.elemParam(as.Rhs[1], recv, params)
+			if l0 < 0 || l1 < 0 || l0 == l1 || r0 != l1 || r1 != l0 {
+				return errSwap
+			}
+			swapped = true
+		case 1:
+			found := false
+			for k := range 2 {
+				t, ok := p.template(as.Lhs[0], recv, params[k], params[1-k])
+				if ok && strings.HasPrefix(t, "\x00.") && strings.Count(t, "\x00") == 1 && p.refersTo(as.Rhs[0], params[k]) {
+					if index != "" && index != t {
+						return errSwap
+					}
+					index = t
+					set[k] = true
+					p.addIdents(ht, as.Lhs[0], recv, params[0], params[1])
+					found = true
+				}
+			}
+			if !found {
+				return errSwap
+			}
+		default:
+			return errSwap
+		}
+	}
+	if !swapped || (index != "" && !(set[0] && set[1])) {
+		return errSwap
+	}
+	if index != "" {
+		if _, ok := ht.elem.Underlying().(*types.Pointer); !ok {
+			return fmt.Errorf("the elements of %s are not pointers, so an index function cannot update them", ht.name.Name())
+		}
+	}
+	ht.index = index
+	return nil
+}
+
+// checkSideEffects reports an error if fd calls a function other than a
+// builtin or modifies a package-level variable. The methods of a
+// [heap.Heap] would not do those things.
+func (p *pass) checkSideEffects(fd *ast.FuncDecl) error {
+	var err error
+	isGlobal := func(e ast.Expr) {
+		for err == nil {
+			switch x := ast.Unparen(e).(type) {
+			case *ast.SelectorExpr:
+				e = x.X
+			case *ast.IndexExpr:
+				e = x.X
+			case *ast.StarExpr:
+				e = x.X
+			case *ast.Ident:
+				switch obj := p.info.Uses[x].(type) {
+				case *types.PkgName:
+					err = fmt.Errorf("%s modifies a variable in package %s", fd.Name.Name, obj.Name())
+				case *types.Var:
+					if obj.Parent() == p.pkg.Scope() {
+						err = fmt.Errorf("%s modifies package-level variable %s", fd.Name.Name, obj.Name())
+					}
+				}
+				return
+			default:
+				return
+			}
+		}
+	}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if p.info.Types[n.Fun].IsType() || p.builtin(n) != "" {
+				return true
+			}
+			err = fmt.Errorf("%s calls %s", fd.Name.Name, p.text(n.Fun))
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				isGlobal(lhs)
+			}
+		case *ast.IncDecStmt:
+			isGlobal(n.X)
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("%w, which a heap.Heap would not do", err)
+	}
+	return nil
+}
+
+// funcObjs returns the receiver and parameters of fd.
+func (p *pass) funcObjs(fd *ast.FuncDecl) (recv types.Object, params []types.Object) {
+	if fd.Recv != nil && len(fd.Recv.List[0].Names) == 1 {
+		recv = p.info.Defs[fd.Recv.List[0].Names[0]]
+	}
+	for _, f := range fd.Type.Params.List {
+		for _, name := range f.Names {
+			params = append(params, p.info.Defs[name])
+		}
+	}
+	return recv, params
+}
+
+// builtin returns the name of the builtin function called by c, or "".
+func (p *pass) builtin(c *ast.CallExpr) string {
+	if id, ok := ast.Unparen(c.Fun).(*ast.Ident); ok {
+		if b, ok := p.info.Uses[id].(*types.Builtin); ok {
+			return b.Name()
+		}
+	}
+	return ""
+}
+
+func (p *pass) refersTo(e ast.Expr, obj types.Object) bool {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	return ok && obj != nil && p.info.Uses[id] == obj
+}
+
+// isRecv reports whether e is recv or *recv.
+func (p *pass) isRecv(e ast.Expr, recv types.Object) bool {
+	e = ast.Unparen(e)
+	if se, ok := e.(*ast.StarExpr); ok {
+		e = se.X
+	}
+	return p.refersTo(e, recv)
+}
+
+// elemParam reports which of params e indexes the receiver with,
+// or -1 if e is not of the form recv[param].
+func (p *pass) elemParam(e ast.Expr, recv types.Object, params []types.Object) int {
+	for k, param := range params {
+		if t, ok := p.template(e, recv, param, params[1-k]); ok && t == "\x00" {
+			return k
+		}
+	}
+	return -1
+}
+
+// template returns the source text of e with each occurrence of recv[idx]
+// replaced by a NUL byte. It reports false if e refers to recv, idx or other
+// in any other way.
+func (p *pass) template(e ast.Expr, recv, idx, other types.Object) (string, bool) {
+	var holes []ast.Node
+	ok := true
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			if p.isRecv(n.X, recv) && p.refersTo(n.Index, idx) {
+				holes = append(holes, n)
+				return false
+			}
+		case *ast.Ident:
+			if obj := p.info.Uses[n]; obj != nil && (obj == recv || obj == idx || obj == other) {
+				ok = false
+			}
+		}
+		return ok
+	})
+	if !ok {
+		return "", false
+	}
+	var b strings.Builder
+	text := p.text(e)
+	prev := e.Pos()
+	for _, h := range holes {
+		b.WriteString(text[prev-e.Pos() : h.Pos()-e.Pos()])
+		b.WriteByte(0)
+		prev = h.End()
+	}
+	b.WriteString(text[prev-e.Pos():])
+	return b.String(), true
+}
+
+// addIdents records the identifiers in n other than those referring to
+// skip, so that the names of parameters in generated functions do not
+// conflict with them.
+func (p *pass) addIdents(ht *heapType, n ast.Node, skip ...types.Object) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && !slices.Contains(skip, p.info.Uses[id]) {
+			ht.idents[id.Name] = true
+		}
+		return true
+	})
+}
+
+// A heapVar is a local variable that holds a heap.
+type heapVar struct {
+	obj   *types.Var
+	ht    *heapType
+	ptr   bool            // the variable has type *T rather than T
+	ident *ast.Ident      // declaring identifier
+	uses  []*ast.Ident    // in order of position
+	calls []*ast.CallExpr // container/heap calls with the variable as heap
+}
+
+// fixFile rewrites the uses of container/heap in f. It returns the new
+// contents of the file, or nil if it cannot rewrite all of them.
+func (p *pass) fixFile(f *ast.File) ([]byte, error) {
+	var spec *ast.ImportSpec
+	for _, s := range f.Imports {
+		if path, _ := strconv.Unquote(s.Path.Value); path == stdHeapPath {
+			spec = s
+		}
+	}
+	if spec == nil {
+		return nil, nil
+	}
+	var pkgName types.Object
+	if spec.Name != nil {
+		pkgName = p.info.Defs[spec.Name]
+	} else {
+		pkgName = p.info.Implicits[spec]
+	}
+	if pkgName == nil || pkgName.Name() == "_" || pkgName.Name() == "." {
+		return nil, nil
+	}
+
+	inFile := func(n ast.Node) bool { return f.Pos() <= n.Pos() && n.End() <= f.End() }
+	uses := map[types.Object][]*ast.Ident{}
+	var pkgUses []*ast.Ident
+	for id, obj := range p.info.Uses {
+		if !inFile(id) {
+			continue
+		}
+		if obj == pkgName {
+			pkgUses = append(pkgUses, id)
+		} else {
+			uses[obj] = append(uses[obj], id)
+		}
+	}
+	for _, ids := range uses {
+		slices.SortFunc(ids, func(a, b *ast.Ident) int { return int(a.Pos() - b.Pos()) })
+	}
+	slices.SortFunc(pkgUses, func(a, b *ast.Ident) int { return int(a.Pos() - b.Pos()) })
+
+	// Find the container/heap calls, and the variables they operate on.
+	calls := map[*ast.CallExpr]types.Object{}
+	for _, id := range pkgUses {
+		sel, ok := p.parents[id].(*ast.SelectorExpr)
+		if !ok {
+			continue
+		}
+		c, ok := p.parent(sel).(*ast.CallExpr)
+		if !ok || ast.Unparen(c.Fun) != sel || len(c.Args) == 0 {
+			continue
+		}
+		arg := ast.Unparen(c.Args[0])
+		if u, ok := arg.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			arg = ast.Unparen(u.X)
+		}
+		var obj types.Object
+		if a, ok := arg.(*ast.Ident); ok {
+			obj = p.info.Uses[a]
+		}
+		calls[c] = obj
+	}
+
+	// Find the variables of heap types, and try to rewrite each one.
+	var vars []*heapVar
+	for id, obj := range p.info.Defs {
+		v, ok := obj.(*types.Var)
+		if !ok || !inFile(id) || v.IsField() || v.Parent() == nil || v.Parent() == p.pkg.Scope() {
+			continue
+		}
+		switch p.parents[id].(type) {
+		case *ast.AssignStmt, *ast.ValueSpec:
+		default:
+			continue // a parameter, receiver or range variable
+		}
+		t, ptr := v.Type(), false
+		if pt, ok := t.(*types.Pointer); ok {
+			t, ptr = pt.Elem(), true
+		}
+		named, ok := t.(*types.Named)
+		if !ok || p.types[named.Obj()] == nil {
+			continue
+		}
+		hv := &heapVar{obj: v, ht: p.types[named.Obj()], ptr: ptr, ident: id, uses: uses[v]}
+		for c, o := range calls {
+			if o == v {
+				hv.calls = append(hv.calls, c)
+			}
+		}
+		if len(hv.calls) > 0 {
+			vars = append(vars, hv)
+		}
+	}
+	slices.SortFunc(vars, func(a, b *heapVar) int { return int(a.ident.Pos() - b.ident.Pos()) })
+
+	heapName := pkgName.Name()
+	handled := map[*ast.CallExpr]bool{}
+	var edits []edit
+	needCmp := false
+	ok := true
+	for _, hv := range vars {
+		for _, c := range hv.calls {
+			handled[c] = true
+		}
+		es, usesCmp, err := p.rewriteVar(f, hv, heapName)
+		if err != nil {
+			p.report(hv.ident.Pos(), "cannot rewrite %s: %v", hv.ident.Name, err)
+			ok = false
+			continue
+		}
+		edits = append(edits, es...)
+		needCmp = needCmp || usesCmp
+	}
+	for _, id := range pkgUses {
+		sel, _ := p.parents[id].(*ast.SelectorExpr)
+		c, isCall := p.parent(sel).(*ast.CallExpr)
+		switch {
+		case isCall && handled[c]:
+			continue
+		case isCall && calls[c] != nil:
+			p.report(c.Pos(), "cannot rewrite %s: %s is not a local variable of a heap type", p.text(c.Fun), p.text(c.Args[0]))
+		default:
+			p.report(id.Pos(), "cannot rewrite use of %s", stdHeapPath)
+		}
+		ok = false
+	}
+	if !ok || len(edits) == 0 {
+		return nil, nil
+	}
+	edits = append(edits, p.importEdits(f, spec, needCmp)...)
+	return p.apply(f, edits)
+}
+
+// rewriteVar returns the edits that replace the container/heap operations on
+// hv with calls to the methods of a heap.Heap. It also reports whether the
+// edits refer to the cmp package.
+func (p *pass) rewriteVar(f *ast.File, hv *heapVar, heapName string) ([]edit, bool, error) {
+	ht := hv.ht
+	if ht.err != nil {
+		return nil, false, ht.err
+	}
+	name := hv.ident.Name
+
+	// Find the declaration and the statement list that contains it.
+	var (
+		declStmt ast.Stmt
+		rhs      ast.Expr
+		spec     *ast.ValueSpec
+	)
+	switch par := p.parents[hv.ident].(type) {
+	case *ast.AssignStmt:
+		if par.Tok != token.DEFINE || len(par.Lhs) != 1 || len(par.Rhs) != 1 {
+			return nil, false, fmt.Errorf("%s is declared with other variables", name)
+		}
+		declStmt, rhs = par, par.Rhs[0]
+	case *ast.ValueSpec:
+		gd, _ := p.parents[par].(*ast.GenDecl)
+		ds, _ := p.parents[gd].(*ast.DeclStmt)
+		if ds == nil || len(gd.Specs) != 1 || len(par.Names) != 1 || len(par.Values) > 1 {
+			return nil, false, fmt.Errorf("%s is declared with other variables", name)
+		}
+		declStmt, spec = ds, par
+		if len(par.Values) == 1 {
+			rhs = par.Values[0]
+		}
+	}
+	list := stmtList(p.parents[declStmt])
+	declIndex := slices.Index(list, declStmt)
+	if declIndex < 0 {
+		return nil, false, fmt.Errorf("unsupported declaration")
+	}
+
+	// Classify the initial value.
+	var (
+		empty     bool
+		typeExpr  ast.Node // the T in the initial value, to be replaced by []E
+		typeStart token.Pos
+	)
+	if rhs == nil {
+		empty, typeExpr = true, spec.Type
+	} else {
+		switch x := ast.Unparen(rhs).(type) {
+		case *ast.CallExpr:
+			switch p.builtin(x) {
+			case "make":
+				if len(x.Args) < 2 {
+					return nil, false, fmt.Errorf("unsupported initial value")
+				}
+				tv := p.info.Types[x.Args[1]]
+				empty = tv.Value != nil && constant.Sign(tv.Value) == 0
+				typeExpr = x.Args[0]
+			case "new":
+				empty = true
+			default:
+				return nil, false, fmt.Errorf("unsupported initial value")
+			}
+		case *ast.CompositeLit:
+			empty, typeExpr = len(x.Elts) == 0, x.Type
+		case *ast.UnaryExpr:
+			cl, ok := ast.Unparen(x.X).(*ast.CompositeLit)
+			if x.Op != token.AND || !ok {
+				return nil, false, fmt.Errorf("unsupported initial value")
+			}
+			empty, typeExpr, typeStart = len(cl.Elts) == 0, cl.Type, x.Pos()
+		default:
+			return nil, false, fmt.Errorf("unsupported initial value")
+		}
+	}
+
+	// Find the call to heap.Init, if any.
+	var initCall *ast.CallExpr
+	for _, c := range hv.calls {
+		if p.heapFunc(c) != "Init" {
+			continue
+		}
+		if initCall != nil {
+			return nil, false, fmt.Errorf("heap.Init is called more than once")
+		}
+		initCall = c
+	}
+	var initStmt ast.Stmt
+	if initCall != nil {
+		es, ok := p.parent(initCall).(*ast.ExprStmt)
+		if !ok || slices.Index(list, ast.Stmt(es)) <= declIndex {
+			return nil, false, fmt.Errorf("heap.Init is not called in the block that declares %s", name)
+		}
+		initStmt = es
+	} else if !empty {
+		return nil, false, fmt.Errorf("%s has initial elements but heap.Init is not called", name)
+	}
+	afterInit := func(n ast.Node) bool { return initStmt == nil || n.Pos() >= initStmt.End() }
+
+	// Before heap.Init, the variable is a slice under a new name.
+	items := name + "Items"
+	var preInit []*ast.Ident
+	for _, id := range hv.uses {
+		if initStmt != nil && id.Pos() < initStmt.Pos() {
+			preInit = append(preInit, id)
+		}
+	}
+	if len(preInit) > 0 || !empty {
+		for _, n := range append([]ast.Node{hv.ident}, nodes(preInit)...) {
+			if _, obj := p.pkg.Scope().Innermost(n.Pos()).LookupParent(items, n.Pos()); obj != nil {
+				return nil, false, fmt.Errorf("%s is already declared", items)
+			}
+		}
+	}
+
+	qual, missing := p.qualifier(f)
+	elem := types.TypeString(ht.elem, qual)
+	ctor, usesCmp := p.constructor(ht, heapName, qual)
+	if *missing != "" {
+		return nil, false, fmt.Errorf("the file does not import %s", *missing)
+	}
+
+	var edits []edit
+	replace := func(n ast.Node, text string) { edits = append(edits, edit{n.Pos(), n.End(), text}) }
+
+	// Rewrite the declaration and the call to heap.Init.
+	switch {
+	case initStmt == nil:
+		replace(declStmt, name+" := "+ctor)
+	case empty && len(preInit) == 0:
+		edits = append(edits, p.deleteStmt(declStmt))
+		replace(initStmt, name+" := "+ctor)
+	default:
+		replace(hv.ident, items)
+		if spec != nil && spec.Type != nil && rhs != nil {
+			replace(spec.Type, "[]"+elem)
+		}
+		if typeStart.IsValid() {
+			edits = append(edits, edit{typeStart, typeExpr.End(), "[]" + elem})
+		} else if typeExpr != nil {
+			replace(typeExpr, "[]"+elem)
+		} else {
+			replace(rhs, "[]"+elem+"(nil)")
+		}
+		replace(initStmt, name+" := "+ctor+"\n"+name+".Init("+items+")")
+	}
+
+	// Rewrite the other uses.
+	for _, id := range hv.uses {
+		if initStmt != nil && initStmt.Pos() <= id.Pos() && id.End() <= initStmt.End() {
+			continue
+		}
+		if !afterInit(id) {
+			if !p.sliceUse(id) {
+				return nil, false, fmt.Errorf("%s is used other than as a slice before heap.Init (line %d)", name, p.line(id))
+			}
+			replace(id, items)
+			continue
+		}
+		if c := p.heapCall(id, hv.ptr); c != nil {
+			e, err := p.rewriteCall(c, hv, name)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, other := range hv.uses {
+				if other != id && c.Pos() <= other.Pos() && other.End() <= c.End() {
+					return nil, false, fmt.Errorf("%s is used in the arguments of %s (line %d)", name, p.text(c.Fun), p.line(c))
+				}
+			}
+			edits = append(edits, e)
+			continue
+		}
+		par := p.parent(id)
+		if c, ok := par.(*ast.CallExpr); ok && !hv.ptr && p.builtin(c) == "len" {
+			replace(c, name+".Len()")
+			continue
+		}
+		if sel, ok := par.(*ast.SelectorExpr); ok && sel.Sel.Name == "Len" {
+			if c, ok := p.parent(sel).(*ast.CallExpr); ok && len(c.Args) == 0 {
+				continue // Heap has a Len method too.
+			}
+		}
+		return nil, false, fmt.Errorf("%s is used other than through %s (line %d)", name, stdHeapPath, p.line(id))
+	}
+	return edits, usesCmp, nil
+}
+
+// heapFunc returns the name of the container/heap function called by c.
+func (p *pass) heapFunc(c *ast.CallExpr) string {
+	sel := ast.Unparen(c.Fun).(*ast.SelectorExpr)
+	return sel.Sel.Name
+}
+
+// heapCall returns the container/heap call whose heap argument is id, or nil.
+func (p *pass) heapCall(id *ast.Ident, ptr bool) *ast.CallExpr {
+	var arg ast.Node = id
+	if !ptr {
+		u, ok := p.parent(id).(*ast.UnaryExpr)
+		if !ok || u.Op != token.AND {
+			return nil
+		}
+		arg = u
+	}
+	c, ok := p.parent(arg).(*ast.CallExpr)
+	if !ok || len(c.Args) == 0 || ast.Unparen(c.Args[0]) != arg {
+		return nil
+	}
+	sel, ok := ast.Unparen(c.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	if pn, ok := p.info.Uses[x].(*types.PkgName); !ok || pn.Imported().Path() != stdHeapPath {
+		return nil
+	}
+	return c
+}
+
+// sliceUse reports whether id is used in a way that would work as well
+// if its type were []E instead of T.
+func (p *pass) sliceUse(id *ast.Ident) bool {
+	switch par := p.parent(id).(type) {
+	case *ast.IndexExpr:
+		return ast.Unparen(par.X) == id
+	case *ast.RangeStmt:
+		return par.X == id
+	case *ast.CallExpr:
+		switch p.builtin(par) {
+		case "len", "cap", "append", "copy":
+			return true
+		}
+	case *ast.AssignStmt:
+		if par.Tok != token.ASSIGN || len(par.Lhs) != 1 || len(par.Rhs) != 1 {
+			return false
+		}
+		c, ok := ast.Unparen(par.Rhs[0]).(*ast.CallExpr)
+		return ok && p.builtin(c) == "append"
+	}
+	return false
+}
+
+// rewriteCall returns the edit that replaces the container/heap call c with
+// a method call on the heap.Heap named name.
+func (p *pass) rewriteCall(c *ast.CallExpr, hv *heapVar, name string) (edit, error) {
+	fn := p.heapFunc(c)
+	line := p.line(c)
+	arg := func(i int) string {
+		if i < len(c.Args) {
+			return p.text(c.Args[i])
+		}
+		return ""
+	}
+	checkIndex := func() error {
+		if hv.ht.index != "" {
+			return nil
+		}
+		if tv := p.info.Types[c.Args[1]]; tv.Value != nil && constant.Sign(tv.Value) == 0 {
+			return nil
+		}
+		return fmt.Errorf("heap.%s is called with an index that %s does not maintain (line %d)", fn, hv.ht.name.Name(), line)
+	}
+	par := p.parent(c)
+	_, isStmt := par.(*ast.ExprStmt)
+	switch fn {
+	case "Push":
+		if len(c.Args) != 2 {
+			break
+		}
+		if t := p.info.Types[c.Args[1]].Type; t == nil || !types.AssignableTo(t, hv.ht.elem) {
+			return edit{}, fmt.Errorf("the value pushed at line %d is not a %s", line, hv.ht.elem)
+		}
+		return edit{c.Pos(), c.End(), name + ".Insert(" + arg(1) + ")"}, nil
+	case "Pop":
+		if ta, ok := par.(*ast.TypeAssertExpr); ok && ta.Type != nil && types.Identical(p.info.Types[ta.Type].Type, hv.ht.elem) {
+			return edit{ta.Pos(), ta.End(), name + ".TakeMin()"}, nil
+		}
+		if isStmt || p.interfaceArg(c) {
+			return edit{c.Pos(), c.End(), name + ".TakeMin()"}, nil
+		}
+		return edit{}, fmt.Errorf("the result of heap.Pop is used as an interface value (line %d)", line)
+	case "Fix":
+		if err := checkIndex(); err != nil {
+			return edit{}, err
+		}
+		return edit{c.Pos(), c.End(), name + ".Changed(" + arg(1) + ")"}, nil
+	case "Remove":
+		if !isStmt {
+			return edit{}, fmt.Errorf("the result of heap.Remove is used (line %d)", line)
+		}
+		if err := checkIndex(); err != nil {
+			return edit{}, err
+		}
+		return edit{c.Pos(), c.End(), name + ".Delete(" + arg(1) + ")"}, nil
+	}
+	return edit{}, fmt.Errorf("unsupported call to heap.%s (line %d)", fn, line)
+}
+
+// interfaceArg reports whether c is an argument to a function whose
+// corresponding parameter has an interface type.
+func (p *pass) interfaceArg(c *ast.CallExpr) bool {
+	call, ok := p.parent(c).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sig, ok := p.info.Types[call.Fun].Type.(*types.Signature)
+	if !ok {
+		return false
+	}
+	i := slices.IndexFunc(call.Args, func(e ast.Expr) bool { return ast.Unparen(e) == c })
+	params := sig.Params()
+	var t types.Type
+	switch {
+	case i < 0:
+		return false
+	case sig.Variadic() && i >= params.Len()-1 && !call.Ellipsis.IsValid():
+		t = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+	case i < params.Len():
+		t = params.At(i).Type()
+	default:
+		return false
+	}
+	return types.IsInterface(t)
+}
+
+// qualifier returns a qualifier for printing types in f. If a type refers to
+// a package that f does not import, the qualifier sets *missing to its path.
+func (p *pass) qualifier(f *ast.File) (types.Qualifier, *string) {
+	missing := new(string)
+	return func(pkg *types.Package) string {
+		if pkg == p.pkg {
+			return ""
+		}
+		for _, s := range f.Imports {
+			if path, _ := strconv.Unquote(s.Path.Value); path == pkg.Path() {
+				if s.Name != nil {
+					return s.Name.Name
+				}
+				return pkg.Name()
+			}
+		}
+		*missing = pkg.Path()
+		return pkg.Name()
+	}, missing
+}
+
+// constructor returns an expression that creates a heap.Heap that behaves
+// like ht. It also reports whether the expression refers to the cmp package.
+func (p *pass) constructor(ht *heapType, heapName string, qual types.Qualifier) (string, bool) {
+	elem := types.TypeString(ht.elem, qual)
+	a, b := pickNames(ht.idents, "a", "b")
+	var compare string
+	usesCmp := ht.key != "" && p.pkg.Scope().Lookup("cmp") == nil
+	switch {
+	case usesCmp && ht.key == "\x00" && !ht.reversed:
+		compare = fmt.Sprintf("cmp.Compare[%s]", elem)
+	case usesCmp:
+		x, y := strings.ReplaceAll(ht.key, "\x00", a), strings.ReplaceAll(ht.key, "\x00", b)
+		if ht.reversed {
+			x, y = y, x
+		}
+		compare = fmt.Sprintf("func(%s, %s %s) int {\nreturn cmp.Compare(%s, %s)\n}", a, b, elem, x, y)
+	default:
+		// Let the Less method compare the elements.
+		compare = fmt.Sprintf(`func(%[1]s, %[2]s %[3]s) int {
+			s := %[4]s{%[1]s, %[2]s}
+			if s.Less(0, 1) {
+				return -1
+			}
+			if s.Less(1, 0) {
+				return 1
+			}
+			return 0
+		}`, a, b, elem, types.TypeString(ht.name.Type(), qual))
+	}
+	if ht.index == "" {
+		return fmt.Sprintf("%s.New(%s)", heapName, compare), usesCmp
+	}
+	e, i := pickNames(ht.idents, "e", "i")
+	setIndex := fmt.Sprintf("func(%s %s, %s int) { %s = %s }", e, elem, i, strings.ReplaceAll(ht.index, "\x00", e), i)
+	return fmt.Sprintf("%s.NewIndexed(%s, %s)", heapName, compare, setIndex), usesCmp
+}
+
+// pickNames returns x and y, or other names if they appear in used.
+func pickNames(used map[string]bool, x, y string) (string, string) {
+	for n := 0; ; n++ {
+		a, b := x, y
+		if n > 0 {
+			a, b = fmt.Sprint(x, n), fmt.Sprint(y, n)
+		}
+		if !used[a] && !used[b] {
+			return a, b
+		}
+	}
+}
+
+// importEdits returns the edits that replace the import of container/heap
+// with an import of this package, and add an import of cmp if needed.
+func (p *pass) importEdits(f *ast.File, spec *ast.ImportSpec, needCmp bool) []edit {
+	newSpec := strconv.Quote(heapPath)
+	if spec.Name != nil {
+		newSpec = spec.Name.Name + " " + newSpec
+	}
+	hasCmp := false
+	for _, s := range f.Imports {
+		if path, _ := strconv.Unquote(s.Path.Value); path == "cmp" {
+			hasCmp = true
+		}
+	}
+	needCmp = needCmp && !hasCmp
+
+	var gd *ast.GenDecl
+	for _, d := range f.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && slices.Contains(g.Specs, ast.Spec(spec)) {
+			gd = g
+		}
+	}
+	tf := p.fset.File(f.Pos())
+	if !gd.Lparen.IsValid() {
+		text := "import " + newSpec
+		if needCmp {
+			text = "import (\n\t\"cmp\"\n\n\t" + newSpec + "\n)"
+		}
+		return []edit{{gd.Pos(), gd.End(), text}}
+	}
+	var edits []edit
+	if needCmp {
+		pos := tf.LineStart(tf.Line(gd.Lparen) + 1)
+		edits = append(edits, edit{pos, pos, "\t\"cmp\"\n"})
+	}
+	// Move the import to the last group, or to a new group if the last one
+	// has only standard packages.
+	line := tf.Line(spec.Pos())
+	rline := tf.Line(gd.Rparen)
+	if tf.Line(spec.End()) != line || line >= rline || p.otherOnLine(gd, spec, line) {
+		return append(edits, edit{spec.Path.Pos(), spec.Path.End(), strconv.Quote(heapPath)})
+	}
+	var last *ast.ImportSpec
+	for _, s := range gd.Specs {
+		if s != spec {
+			last = s.(*ast.ImportSpec)
+		}
+	}
+	start := tf.LineStart(line)
+	edits = append(edits, edit{start, tf.LineStart(line + 1), ""})
+	text := "\t" + newSpec + "\n"
+	if last != nil {
+		path, _ := strconv.Unquote(last.Path.Value)
+		if first, _, _ := strings.Cut(path, "/"); !strings.Contains(first, ".") {
+			text = "\n" + text
+		}
+	}
+	rstart := tf.LineStart(rline)
+	return append(edits, edit{rstart, rstart, text})
+}
+
+// otherOnLine reports whether anything other than spec and a comment
+// appears on the given line of gd.
+func (p *pass) otherOnLine(gd *ast.GenDecl, spec *ast.ImportSpec, line int) bool {
+	tf := p.fset.File(gd.Pos())
+	for _, s := range gd.Specs {
+		if s != spec && tf.Line(s.Pos()) == line {
+			return true
+		}
+	}
+	return tf.Line(gd.Lparen) == line
+}
+
+// deleteStmt returns an edit that deletes s, and the line it is on
+// if nothing else is there.
+func (p *pass) deleteStmt(s ast.Stmt) edit {
+	tf := p.fset.File(s.Pos())
+	src := p.src[tf]
+	start, end := tf.Offset(s.Pos()), tf.Offset(s.End())
+	ls := tf.Offset(tf.LineStart(tf.Line(s.Pos())))
+	le := end
+	for le < len(src) && (src[le] == ' ' || src[le] == '\t') {
+		le++
+	}
+	if strings.TrimSpace(string(src[ls:start])) == "" && le < len(src) && src[le] == '\n' {
+		return edit{tf.Pos(ls), tf.Pos(le + 1), ""}
+	}
+	return edit{s.Pos(), s.End(), ""}
+}
+
+// apply applies the edits to f and formats the result.
+func (p *pass) apply(f *ast.File, edits []edit) ([]byte, error) {
+	tf := p.fset.File(f.Pos())
+	slices.SortFunc(edits, func(a, b edit) int {
+		if a.pos != b.pos {
+			return int(b.pos - a.pos)
+		}
+		return int(b.end - a.end)
+	})
+	src := slices.Clone(p.src[tf])
+	for i, e := range edits {
+		if i > 0 && e.end > edits[i-1].pos {
+			return nil, fmt.Errorf("%s: internal error: overlapping edits", p.fset.Position(e.pos))
+		}
+		start, end := tf.Offset(e.pos), tf.Offset(e.end)
+		src = slices.Concat(src[:start], []byte(e.text), src[end:])
+	}
+	out, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: internal error: rewritten file does not parse: %v", p.names[f], err)
+	}
+	return out, nil
+}
+
+// stmtList returns the statements of a block, case clause or select clause.
+func stmtList(n ast.Node) []ast.Stmt {
+	switch n := n.(type) {
+	case *ast.BlockStmt:
+		return n.List
+	case *ast.CaseClause:
+		return n.Body
+	case *ast.CommClause:
+		return n.Body
+	}
+	return nil
+}
+
+func nodes(ids []*ast.Ident) []ast.Node {
+	ns := make([]ast.Node, len(ids))
+	for i, id := range ids {
+		ns[i] = id
+	}
+	return ns
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestFix runs heapfix on each file in testdata. A comment of the form
+//
+//	// want "regexp"
+//
+// on a line means that heapfix should report a diagnostic on that line
+// matching the regexp. If file.go is rewritten, the result must match
+// file.go.golden.
+func TestFix(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			res, err := fix([]string{file})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDiagnostics(t, file, res.diags)
+
+			golden := file + ".golden"
+			got, changed := res.changed[file]
+			if *update {
+				if changed {
+					if err := os.WriteFile(golden, got, 0666); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if os.IsNotExist(err) {
+				if changed {
+					t.Fatalf("file was rewritten, but there is no golden file:\n%s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !changed {
+				t.Fatal("file was not rewritten")
+			}
+			if string(got) != string(want) {
+				t.Errorf("mismatch with golden file:\n%s", unifiedDiff(golden, want, got))
+			}
+		})
+	}
+}
+
+var wantRegexp = regexp.MustCompile(`// want (".*")$`)
+
+func checkDiagnostics(t *testing.T, file string, diags []diagnostic) {
+	t.Helper()
+	src, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wants := map[int]*regexp.Regexp{}
+	for i, line := range strings.Split(string(src), "\n") {
+		m := wantRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		pat, err := strconv.Unquote(m[1])
+		if err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		wants[i+1] = regexp.MustCompile(pat)
+	}
+	for _, d := range diags {
+		re := wants[d.pos.Line]
+		if re == nil || !re.MatchString(d.msg) {
+			t.Errorf("unexpected diagnostic: %s", d)
+			continue
+		}
+		delete(wants, d.pos.Line)
+	}
+	for line, re := range wants {
+		t.Errorf("line %d: no diagnostic matching %q", line, re)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	want := `diff -u x.orig x
+--- x.orig
++++ x
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	if got := string(unifiedDiff("x", []byte(old), []byte(new))); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,173 @@
+// Heapfix rewrites programs that use container/heap to use
+// github.com/jba/heap instead.
+//
+// Usage:
+//
+//	heapfix [-w] [path ...]
+//
+// Each path is a directory, a directory followed by "/..." to include its
+// subdirectories, or a Go file. With no paths, heapfix processes the current
+// directory.
+//
+// Heapfix looks for types that implement container/heap.Interface in the
+// usual way: a slice type whose Swap method exchanges two elements and
+// perhaps records their new positions in an index field. It then looks for
+// local variables of those types that are used only as slices before the
+// call to heap.Init, and only through the container/heap functions after it.
+// It replaces each such variable with a *heap.Heap created by [heap.New], or
+// by [heap.NewIndexed] if Swap maintains an index field. The comparison
+// function is derived from the Less method, and the index function from Swap.
+//
+// For example, heapfix rewrites
+//
+//	h := &IntHeap{2, 1, 5}
+//	heap.Init(h)
+//	heap.Push(h, 3)
+//	for h.Len() > 0 {
+//		fmt.Println(heap.Pop(h))
+//	}
+//
+// to
+//
+//	hItems := []int{2, 1, 5}
+//	h := heap.New(cmp.Compare[int])
+//	h.Init(hItems)
+//	h.Insert(3)
+//	for h.Len() > 0 {
+//		fmt.Println(h.TakeMin())
+//	}
+//
+// The heap.Interface type and its methods are left in place.
+//
+// A file is rewritten only if every use of container/heap in it can be
+// rewritten. Otherwise heapfix prints a diagnostic for each use that it
+// cannot rewrite, explaining why, and leaves the file unchanged.
+//
+// By default heapfix prints the changes it would make as a diff.
+// The -w flag writes them to the source files instead.
+//
+// Heapfix exits with status 1 if it printed any diagnostics.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var write = flag.Bool("w", false, "write changes to source files instead of printing a diff")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: heapfix [-w] [path ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	exit := 0
+	for _, path := range paths {
+		pkgs, err := packages(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "heapfix: %v\n", err)
+			os.Exit(2)
+		}
+		for _, files := range pkgs {
+			res, err := fix(files)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "heapfix: %v\n", err)
+				exit = 2
+				continue
+			}
+			for _, d := range res.diags {
+				fmt.Fprintln(os.Stderr, d)
+				exit = max(exit, 1)
+			}
+			for _, name := range files {
+				src, ok := res.changed[name]
+				if !ok {
+					continue
+				}
+				if *write {
+					if err := os.WriteFile(name, src, 0666); err != nil {
+						fmt.Fprintf(os.Stderr, "heapfix: %v\n", err)
+						exit = 2
+					}
+					continue
+				}
+				old, err := os.ReadFile(name)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "heapfix: %v\n", err)
+					exit = 2
+					continue
+				}
+				os.Stdout.Write(unifiedDiff(name, old, src))
+			}
+		}
+	}
+	os.Exit(exit)
+}
+
+// packages returns the Go files denoted by path, grouped by package.
+func packages(path string) ([][]string, error) {
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		return [][]string{{path}}, nil
+	}
+	dir, recursive := strings.CutSuffix(path, "/...")
+	if !recursive {
+		return dirPackages(dir)
+	}
+	var pkgs [][]string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if path != dir && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		ps, err := dirPackages(path)
+		pkgs = append(pkgs, ps...)
+		return err
+	})
+	return pkgs, err
+}
+
+// dirPackages returns the Go files in dir, grouped by package.
+// External test files form a package of their own.
+func dirPackages(dir string) ([][]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	var pkg, xtest []string
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") && isExternalTest(f) {
+			xtest = append(xtest, f)
+		} else {
+			pkg = append(pkg, f)
+		}
+	}
+	return slices.DeleteFunc([][]string{pkg, xtest}, func(fs []string) bool { return len(fs) == 0 }), nil
+}
+
+// isExternalTest reports whether the test file belongs to a package
+// whose name ends in "_test".
+func isExternalTest(filename string) bool {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	for line := range strings.Lines(string(src)) {
+		if name, ok := strings.CutPrefix(line, "package "); ok {
+			return strings.HasSuffix(strings.TrimSpace(name), "_test")
+		}
+	}
+	return false
+}
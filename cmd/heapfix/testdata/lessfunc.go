@@ -0,0 +1,42 @@
+package lessfunc
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+type point struct{ x, y int }
+
+// A points heap orders points by x, then y.
+type points []point
+
+func (h points) Len() int { return len(h) }
+
+func (h points) Less(i, j int) bool {
+	if h[i].x != h[j].x {
+		return h[i].x < h[j].x
+	}
+	return h[i].y < h[j].y
+}
+
+func (h points) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *points) Push(x any)   { *h = append(*h, x.(point)) }
+
+func (h *points) Pop() any {
+	old := *h
+	n := len(old)
+	*h = old[:n-1]
+	return old[n-1]
+}
+
+func example(ps []point) {
+	var h points
+	for _, p := range ps {
+		h = append(h, p)
+	}
+	heap.Init(&h)
+	heap.Remove(&h, 0)
+	for len(h) > 0 {
+		fmt.Println(heap.Pop(&h))
+	}
+}
@@ -0,0 +1,71 @@
+package pq
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+type Item struct {
+	value    string
+	priority int
+	index    int
+}
+
+// A PriorityQueue holds Items, highest priority first.
+type PriorityQueue []*Item
+
+func (pq PriorityQueue) Len() int { return len(pq) }
+
+func (pq PriorityQueue) Less(i, j int) bool {
+	return pq[i].priority > pq[j].priority
+}
+
+func (pq PriorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+func (pq *PriorityQueue) Push(x any) {
+	n := len(*pq)
+	item := x.(*Item)
+	item.index = n
+	*pq = append(*pq, item)
+}
+
+func (pq *PriorityQueue) Pop() any {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*pq = old[0 : n-1]
+	return item
+}
+
+func example(items map[string]int) string {
+	pq := make(PriorityQueue, len(items))
+	i := 0
+	for value, priority := range items {
+		pq[i] = &Item{
+			value:    value,
+			priority: priority,
+			index:    i,
+		}
+		i++
+	}
+	heap.Init(&pq)
+
+	item := &Item{value: "orange", priority: 1}
+	heap.Push(&pq, item)
+	item.priority = 5
+	heap.Fix(&pq, item.index)
+
+	var b strings.Builder
+	for pq.Len() > 0 {
+		item := heap.Pop(&pq).(*Item)
+		fmt.Fprintf(&b, "%.2d:%s ", item.priority, item.value)
+	}
+	return b.String()
+}
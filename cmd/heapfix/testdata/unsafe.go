@@ -0,0 +1,99 @@
+package unsafe
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+type Item struct {
+	priority int
+	index    int
+}
+
+type PriorityQueue []*Item
+
+func (pq PriorityQueue) Len() int           { return len(pq) }
+func (pq PriorityQueue) Less(i, j int) bool { return pq[i].priority < pq[j].priority }
+
+func (pq PriorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+func (pq *PriorityQueue) Push(x any) { *pq = append(*pq, x.(*Item)) }
+
+func (pq *PriorityQueue) Pop() any {
+	old := *pq
+	n := len(old)
+	*pq = old[:n-1]
+	return old[n-1]
+}
+
+func (pq *PriorityQueue) update(item *Item, priority int) {
+	item.priority = priority
+	heap.Fix(pq, item.index) // want "cannot rewrite heap.Fix: pq is not a local variable of a heap type"
+}
+
+func escapes() {
+	pq := PriorityQueue{} // want "cannot rewrite pq: pq is used other than through container/heap \\(line 42\\)"
+	item := &Item{priority: 3}
+	heap.Push(&pq, item)
+	pq.update(item, 1)
+}
+
+var swaps int
+
+// A LoggingHeap counts the swaps it makes.
+type LoggingHeap []int
+
+func (h LoggingHeap) Len() int           { return len(h) }
+func (h LoggingHeap) Less(i, j int) bool { return h[i] < h[j] }
+
+func (h LoggingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	swaps++
+}
+
+func (h *LoggingHeap) Push(x any) { *h = append(*h, x.(int)) }
+
+func (h *LoggingHeap) Pop() any {
+	old := *h
+	n := len(old)
+	*h = old[:n-1]
+	return old[n-1]
+}
+
+func logging() {
+	var h LoggingHeap // want "cannot rewrite h: LoggingHeap.Swap does more than exchange elements and record their indexes"
+	heap.Push(&h, 1)
+	fmt.Println(heap.Pop(&h), swaps)
+}
+
+// A Plain heap does not maintain an index.
+type Plain []*Item
+
+func (h Plain) Len() int           { return len(h) }
+func (h Plain) Less(i, j int) bool { return h[i].priority < h[j].priority }
+func (h Plain) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *Plain) Push(x any)        { *h = append(*h, x.(*Item)) }
+
+func (h *Plain) Pop() any {
+	old := *h
+	n := len(old)
+	fmt.Println("pop")
+	*h = old[:n-1]
+	return old[n-1]
+}
+
+func plain(item *Item) {
+	var h Plain // want "cannot rewrite h: Pop calls fmt.Println, which a heap.Heap would not do"
+	heap.Push(&h, item)
+	heap.Remove(&h, item.index)
+}
+
+var global PriorityQueue
+
+func useGlobal() {
+	heap.Push(&global, &Item{}) // want "cannot rewrite heap.Push: &global is not a local variable of a heap type"
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNumCompare(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"1", "2", -1},
+		{"10", "9", 1},
+		{"007", "7", 0},
+		{"  3", "3", 0},
+		{"-1", "1", -1},
+		{"-2", "-10", 1},
+		{"-0", "0", 0},
+		{"-", "", 0},
+		{"abc", "0", 0},
+		{"abc", "-1", 1},
+		{"1.5", "1.50", 0},
+		{"1.5", "1.45", 1},
+		{".5", "0.5", 0},
+		{"-.5", "0", -1},
+		{"-0.0", ".", 0},
+		{"1.", "1", 0},
+		{"1.05", "1", 1},
+		{"-1.05", "-1", -1},
+		{"12345678901234567890", "12345678901234567891", -1},
+		{"1e3", "2", -1},
+		{"+1", "0", 0},
+	} {
+		got := numCompare([]byte(test.a), []byte(test.b))
+		if sign(got) != test.want {
+			t.Errorf("numCompare(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := numCompare([]byte(test.b), []byte(test.a)); sign(got) != -test.want {
+			t.Errorf("numCompare(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func sign(n int) int {
+	return min(max(n, -1), 1)
+}
+
+func TestExtract(t *testing.T) {
+	for _, test := range []struct {
+		key  string
+		tab  int
+		line string
+		want string
+	}{
+		{"2", -1, "a  b c", "  b c"},
+		{"2,2", -1, "a  b c", "  b"},
+		{"2b,2", -1, "a  b c", "b"},
+		{"1.2,1.3", -1, "abcd", "bc"},
+		{"3", -1, "a b", ""},
+		{"2,2", ':', "a:b:c", "b"},
+		{"2", ':', "a:b:c", "b:c"},
+		{"2,2", ':', "a::c", ""},
+		{"2.2,2.3", ':', "a:bcde:f", "cd"},
+		{"2,1", ':', "a:b", ""},
+	} {
+		k, err := parseKey(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(k.extract([]byte(test.line), test.tab)); got != test.want {
+			t.Errorf("key %s, tab %q, line %q: got %q, want %q", test.key, test.tab, test.line, got, test.want)
+		}
+	}
+}
+
+func TestBadArgs(t *testing.T) {
+	for _, args := range []string{
+		"-k0",
+		"-k1.0",
+		"-k1,0",
+		"-k1x",
+		"-kf",
+		"-k1f",
+		"-t::",
+		"-S 10X",
+		"-x",
+		"-k",
+		"--format=xml",
+	} {
+		var out bytes.Buffer
+		if err := run(strings.Fields(args), strings.NewReader(""), &out); err == nil {
+			t.Errorf("%s: succeeded, want error", args)
+		}
+	}
+}
+
+// randomLines returns random lines with blank-separated and colon-separated
+// fields, numbers, and repeated values, to exercise keys.
+func randomLines(r *rand.Rand, n int) string {
+	words := []string{
+		"", "a", "b", "B", "ab", "abc", "0", "00", "-0", "1", "01", "-1", "2",
+		"10", "-10", "1.5", "1.50", "-1.5", ".5", "-.5", "0.0", "1e3", "x1",
+		"99999999999999999999", "-", ".", "1.", " 3", "\t4",
+	}
+	seps := []string{" ", "  ", "\t", ":", " :", ": "}
+	var b strings.Builder
+	for range n {
+		if r.IntN(20) == 0 {
+			b.WriteString("\n")
+			continue
+		}
+		if r.IntN(5) == 0 {
+			b.WriteString(strings.Repeat(" ", r.IntN(3)))
+		}
+		for i := range r.IntN(5) + 1 {
+			if i > 0 {
+				b.WriteString(seps[r.IntN(len(seps))])
+			}
+			b.WriteString(words[r.IntN(len(words))])
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var sortFlags = []string{
+	"",
+	"-r",
+	"-n",
+	"-nr",
+	"-u",
+	"-ru",
+	"-nu",
+	"-b",
+	"-bn",
+	"-s",
+	"-k2",
+	"-k2,2",
+	"-k2,2 -s",
+	"-k2,2 -u",
+	"-k2,2 -ur",
+	"-k2,2n",
+	"-k2,2n -r",
+	"-k2,2nr -k1,1",
+	"-k3,3n -k1,1r -u",
+	"-nk2",
+	"-n -k2,2 -k3,3r",
+	"-b -k2,2",
+	"-k2b,2",
+	"-k2b,3b",
+	"-k1.2,1.3",
+	"-k2.2b,3.1b",
+	"-k2.2,2",
+	"-k1,1.1",
+	"-t: -k2,2",
+	"-t: -k2",
+	"-t : -k3n -u",
+	"-t: -k2.2,2.2",
+	"-t: -k2,2n -s",
+	"-t: -k1,1 -k2,2rn",
+	"-t: -k2,1",
+	"-s -r -k1,1",
+	"-su -k1,1",
+}
+
+// gnuSort returns the path of GNU sort, or skips the test if there is none.
+func gnuSort(t *testing.T) string {
+	path, err := exec.LookPath("sort")
+	if err != nil {
+		t.Skip("no sort command")
+	}
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil || !bytes.Contains(out, []byte("GNU coreutils")) {
+		t.Skip("sort is not GNU sort")
+	}
+	return path
+}
+
+func runGNUSort(t *testing.T, path string, args []string, stdin string) string {
+	t.Helper()
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sort %s: %v", strings.Join(args, " "), err)
+	}
+	return string(out)
+}
+
+func runHeapsort(t *testing.T, args []string, stdin string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, strings.NewReader(stdin), &out); err != nil {
+		t.Fatalf("heapsort %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+// TestGNUSort checks that heapsort's output matches that of GNU sort, both
+// when the input fits in memory and when it is sorted in runs.
+func TestGNUSort(t *testing.T) {
+	sortPath := gnuSort(t)
+	r := rand.New(rand.NewPCG(1, 2))
+	inputs := []string{"", "a", "b\na\n", randomLines(r, 50), randomLines(r, 500)}
+	for _, flags := range sortFlags {
+		args := strings.Fields(flags)
+		for i, in := range inputs {
+			want := runGNUSort(t, sortPath, args, in)
+			for _, mem := range []string{"", "-S1b", "-S2K"} {
+				hargs := args
+				if mem != "" {
+					hargs = append([]string{mem, "-T", t.TempDir()}, args...)
+				}
+				if got := runHeapsort(t, hargs, in); got != want {
+					t.Errorf("flags %q, input %d, memory %q: output differs from GNU sort:\n%s",
+						flags, i, mem, lineDiff(got, want))
+				}
+			}
+		}
+	}
+}
+
+// TestGNUMerge checks merging with -m against GNU sort, with more files
+// than can be merged at once.
+func TestGNUMerge(t *testing.T) {
+	sortPath := gnuSort(t)
+	r := rand.New(rand.NewPCG(3, 4))
+	dir := t.TempDir()
+	for _, flags := range []string{"", "-n", "-r", "-u", "-k2,2 -s", "-k2,2n -u", "-t: -k2,2r"} {
+		args := strings.Fields(flags)
+		var files []string
+		for i := range maxMergeFiles + 8 {
+			name := filepath.Join(dir, fmt.Sprintf("in%d", i))
+			sorted := runGNUSort(t, sortPath, args, randomLines(r, r.IntN(20)))
+			if err := os.WriteFile(name, []byte(sorted), 0o666); err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, name)
+		}
+		margs := append(append([]string{"-m"}, args...), files...)
+		want := runGNUSort(t, sortPath, margs, "")
+		if got := runHeapsort(t, margs, ""); got != want {
+			t.Errorf("flags %q: output differs from GNU sort:\n%s", flags, lineDiff(got, want))
+		}
+	}
+}
+
+// lineDiff describes the first difference between two outputs.
+func lineDiff(got, want string) string {
+	g, w := strings.SplitAfter(got, "\n"), strings.SplitAfter(want, "\n")
+	for i := range min(len(g), len(w)) {
+		if g[i] != w[i] {
+			return fmt.Sprintf("line %d: got %q, want %q", i+1, g[i], w[i])
+		}
+	}
+	return fmt.Sprintf("got %d lines, want %d", len(g), len(w))
+}
+
+func TestFormats(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		args  string
+		input string
+		want  string
+	}{
+		{
+			name:  "tsv",
+			args:  "--format=tsv -k2,2n",
+			input: "a b\t10\nc d\t9\n",
+			want:  "c d\t9\na b\t10\n",
+		},
+		{
+			name: "csv",
+			args: "--format csv -k2,2n",
+			input: "x,10\n" +
+				"\"multi\nline\",2\n" +
+				"\n" +
+				"\"a,b\",-1\r\n",
+			want: "\"a,b\",-1\r\n" +
+				"\"multi\nline\",2\n" +
+				"x,10\n",
+		},
+		{
+			name:  "csv separator",
+			args:  "--format=csv -t; -k2,2 -k1,1r",
+			input: "a;b\nc;\"b\"\nd;a",
+			want:  "d;a\nc;\"b\"\na;b\n",
+		},
+		{
+			name: "jsonl",
+			args: "--format=jsonl -k user.age:n -k name",
+			input: `{"name":"c","user":{"age":30}}` + "\n" +
+				`{"name":"b","user":{"age":4}}` + "\n" +
+				`{"name":"a","user":{"age":30}}` + "\n" +
+				`{"name":"z"}` + "\n",
+			want: `{"name":"z"}` + "\n" +
+				`{"name":"b","user":{"age":4}}` + "\n" +
+				`{"name":"a","user":{"age":30}}` + "\n" +
+				`{"name":"c","user":{"age":30}}` + "\n",
+		},
+		{
+			name:  "jsonl unique",
+			args:  "--format=jsonl -u -k k:r",
+			input: `{"k":"a","v":1}` + "\n" + `{"k":"b","v":2}` + "\n" + `{"k":"a","v":3}` + "\n",
+			want:  `{"k":"b","v":2}` + "\n" + `{"k":"a","v":1}` + "\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, mem := range []string{"", "-S1b"} {
+				args := strings.Fields(test.args)
+				if mem != "" {
+					args = append(args, mem, "-T", t.TempDir())
+				}
+				if got := runHeapsort(t, args, test.input); got != test.want {
+					t.Errorf("memory %q:\ngot\n%s\nwant\n%s", mem, got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidJSON(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"--format=jsonl", "-k", "a"}, strings.NewReader("{\"a\":1}\n{\n"), &out)
+	if err == nil || !strings.Contains(err.Error(), "-:2:") {
+		t.Errorf("got %v, want error on line 2", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A record is a unit of input to be sorted.
+type record struct {
+	line []byte   // the record as read, without its final newline
+	keys [][]byte // the text of each key
+	seq  int      // position in the input, for stable sorting
+	size int64    // estimated memory used by the record
+}
+
+// recordOverhead is an estimate of the memory used by a record other than
+// its text.
+const recordOverhead = 64
+
+// csvFieldSep separates the decoded fields of a CSV record in the text from
+// which keys are extracted.
+const csvFieldSep = 0
+
+// A recordReader reads records. Its next method returns io.EOF at the end
+// of the input.
+type recordReader interface {
+	next() (*record, error)
+}
+
+func (s *sorter) newReader(r io.Reader, name string) recordReader {
+	if s.csvComma != 0 {
+		t := &teeReader{r: r}
+		cr := csv.NewReader(t)
+		cr.Comma = s.csvComma
+		cr.FieldsPerRecord = -1
+		return &csvReader{s: s, name: name, cr: cr, t: t}
+	}
+	return &lineReader{s: s, name: name, r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// A lineReader reads a record from each line.
+type lineReader struct {
+	s      *sorter
+	name   string
+	r      *bufio.Reader
+	lineno int
+}
+
+func (lr *lineReader) next() (*record, error) {
+	line, err := lr.r.ReadBytes('\n')
+	if len(line) == 0 {
+		return nil, err
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	lr.lineno++
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if !lr.s.json {
+		return lr.s.newRecord(line), nil
+	}
+	r, err := lr.s.newJSONRecord(line)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%d: %v", lr.name, lr.lineno, err)
+	}
+	return r, nil
+}
+
+// newRecord returns a record for a line.
+func (s *sorter) newRecord(line []byte) *record {
+	r := &record{line: line, keys: make([][]byte, len(s.keys))}
+	r.size = int64(len(line)) + recordOverhead
+	for i, k := range s.keys {
+		r.keys[i] = k.extract(line, s.tab)
+	}
+	return r
+}
+
+// newJSONRecord returns a record for a line containing a JSON value.
+// Keys that name fields take their text from the value; any other key is
+// extracted from the whole line.
+func (s *sorter) newJSONRecord(line []byte) (*record, error) {
+	var v any
+	if len(bytes.TrimSpace(line)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+	}
+	r := &record{line: line, keys: make([][]byte, len(s.keys))}
+	r.size = int64(len(line)) + recordOverhead
+	for i, k := range s.keys {
+		if k.path == nil {
+			r.keys[i] = k.extract(line, s.tab)
+			continue
+		}
+		text := jsonText(v, k.path)
+		if k.skipsblanks {
+			text = text[skipBlanks(text, 0):]
+		}
+		r.keys[i] = text
+		r.size += int64(len(text))
+	}
+	return r, nil
+}
+
+// jsonText returns the text of the field of v at path.
+func jsonText(v any, path []string) []byte {
+	for _, name := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(v)
+	case json.Number:
+		return []byte(v)
+	default:
+		b, _ := json.Marshal(v)
+		return b
+	}
+}
+
+// A csvReader reads CSV records, keeping the text of each record so that
+// it can be written unchanged.
+type csvReader struct {
+	s    *sorter
+	name string
+	cr   *csv.Reader
+	t    *teeReader
+	off  int64 // input offset of t.buf[0]
+}
+
+func (c *csvReader) next() (*record, error) {
+	start := c.cr.InputOffset()
+	fields, err := c.cr.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", c.name, err)
+	}
+	end := c.cr.InputOffset()
+	raw := c.t.buf[start-c.off : end-c.off]
+	// The reader skips blank lines before a record.
+	for len(raw) > 0 && (raw[0] == '\n' || raw[0] == '\r' && len(raw) > 1 && raw[1] == '\n') {
+		raw = raw[bytes.IndexByte(raw, '\n')+1:]
+	}
+	line := bytes.Clone(bytes.TrimSuffix(raw, []byte("\n")))
+	c.t.buf = c.t.buf[:copy(c.t.buf, c.t.buf[end-c.off:])]
+	c.off = end
+
+	var text []byte
+	for i, f := range fields {
+		if i > 0 {
+			text = append(text, csvFieldSep)
+		}
+		text = append(text, f...)
+	}
+	r := &record{line: line, keys: make([][]byte, len(c.s.keys))}
+	r.size = int64(len(line)+len(text)) + recordOverhead
+	for i, k := range c.s.keys {
+		r.keys[i] = k.extract(text, c.s.tab)
+	}
+	return r, nil
+}
+
+// A teeReader keeps the bytes read from r that have not yet been consumed.
+type teeReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (t *teeReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.buf = append(t.buf, p[:n]...)
+	return n, err
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A keyDef describes a sort key. The field and character positions are
+// zero-based, and follow the conventions of GNU sort.
+type keyDef struct {
+	sword, schar int // start field and character; sword < 0 for the whole line
+	eword, echar int // end field and character; eword < 0 for the end of the line; echar 0 for the end of the field
+
+	skipsblanks bool // skip blanks at the start of the key
+	skipeblanks bool // skip blanks before the end character
+	numeric     bool
+	reverse     bool
+
+	path []string // for JSON lines, the path of the field
+}
+
+// hasOptions reports whether k has ordering options of its own, other
+// than reverse. A key without them inherits the global options.
+func (k *keyDef) hasOptions() bool {
+	return k.skipsblanks || k.skipeblanks || k.numeric
+}
+
+// parseKey parses a key definition of the form F[.C][opts][,F[.C][opts]].
+func parseKey(def string) (*keyDef, error) {
+	k := &keyDef{eword: -1}
+	bad := func(msg string) error {
+		return fmt.Errorf("invalid key %q: %s", def, msg)
+	}
+	s := def
+	f, s, err := parseCount(s)
+	if err != nil {
+		return nil, bad("invalid field number")
+	}
+	if f == 0 {
+		return nil, bad("field number is zero")
+	}
+	k.sword = f - 1
+	if rest, ok := strings.CutPrefix(s, "."); ok {
+		var c int
+		if c, s, err = parseCount(rest); err != nil {
+			return nil, bad("invalid number after '.'")
+		}
+		if c == 0 {
+			return nil, bad("character offset is zero")
+		}
+		k.schar = c - 1
+	}
+	if s, err = k.parseOptions(s, true); err != nil {
+		return nil, bad(err.Error())
+	}
+	if rest, ok := strings.CutPrefix(s, ","); ok {
+		if f, s, err = parseCount(rest); err != nil {
+			return nil, bad("invalid number after ','")
+		}
+		if f == 0 {
+			return nil, bad("field number is zero")
+		}
+		k.eword = f - 1
+		if rest, ok := strings.CutPrefix(s, "."); ok {
+			if k.echar, s, err = parseCount(rest); err != nil {
+				return nil, bad("invalid number after '.'")
+			}
+		}
+		if s, err = k.parseOptions(s, false); err != nil {
+			return nil, bad(err.Error())
+		}
+	}
+	if s != "" {
+		return nil, bad("stray character in field spec")
+	}
+	return k, nil
+}
+
+// parseJSONKey parses a key definition of the form path[:opts].
+func parseJSONKey(def string) (*keyDef, error) {
+	k := &keyDef{sword: -1, eword: -1}
+	path := def
+	if i := strings.LastIndexByte(def, ':'); i >= 0 {
+		path = def[:i]
+		if rest, err := k.parseOptions(def[i+1:], true); err != nil || rest != "" {
+			return nil, fmt.Errorf("invalid key %q: invalid options", def)
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("invalid key %q: empty field name", def)
+	}
+	k.path = strings.Split(path, ".")
+	return k, nil
+}
+
+// parseCount parses the decimal number at the start of s.
+func parseCount(s string) (int, string, error) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	n, err := strconv.Atoi(s[:i])
+	return n, s[i:], err
+}
+
+// parseOptions parses the ordering options at the start of s.
+// A 'b' applies to the start of the key if start is true, and to its end
+// otherwise.
+func (k *keyDef) parseOptions(s string, start bool) (string, error) {
+	for ; s != ""; s = s[1:] {
+		switch s[0] {
+		case 'b':
+			if start {
+				k.skipsblanks = true
+			} else {
+				k.skipeblanks = true
+			}
+		case 'n':
+			k.numeric = true
+		case 'r':
+			k.reverse = true
+		case ',', '.':
+			return s, nil
+		default:
+			if 'a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z' {
+				return "", fmt.Errorf("unsupported ordering option '%c'", s[0])
+			}
+			return s, nil
+		}
+	}
+	return s, nil
+}
+
+// setGlobal applies the global ordering options to the keys that have none
+// of their own. If there are no keys, but the options affect comparisons
+// other than by reversing them, it adds a key for the whole line.
+func (s *sorter) setGlobal(g keyDef) {
+	for _, k := range s.keys {
+		if !k.hasOptions() && !k.reverse {
+			k.skipsblanks, k.skipeblanks = g.skipsblanks, g.skipeblanks
+			k.numeric = g.numeric
+			k.reverse = g.reverse
+		}
+	}
+	if len(s.keys) == 0 && g.hasOptions() {
+		g.sword, g.eword = -1, -1
+		s.keys = []*keyDef{&g}
+	}
+	s.reverse = g.reverse
+}
+
+// extract returns the text of the key in the text of a record, as divided
+// into fields by tab, or by blanks if tab is negative.
+func (k *keyDef) extract(text []byte, tab int) []byte {
+	beg := 0
+	if k.sword >= 0 {
+		beg = k.begfield(text, tab)
+	} else if k.skipsblanks {
+		beg = skipBlanks(text, 0)
+	}
+	lim := len(text)
+	if k.eword >= 0 {
+		lim = k.limfield(text, tab)
+	}
+	// A key that ends before it starts is empty.
+	return text[beg:max(beg, lim)]
+}
+
+// begfield returns the offset of the start of the key in text.
+func (k *keyDef) begfield(text []byte, tab int) int {
+	p, lim := 0, len(text)
+	for sword := k.sword; p < lim && sword > 0; sword-- {
+		if tab >= 0 {
+			for p < lim && int(text[p]) != tab {
+				p++
+			}
+			if p < lim {
+				p++
+			}
+		} else {
+			p = skipBlanks(text, p)
+			p = skipNonBlanks(text, p)
+		}
+	}
+	if k.skipsblanks {
+		p = skipBlanks(text, p)
+	}
+	return min(lim, p+k.schar)
+}
+
+// limfield returns the offset of the end of the key in text.
+func (k *keyDef) limfield(text []byte, tab int) int {
+	p, lim := 0, len(text)
+	eword, echar := k.eword, k.echar
+	if echar == 0 {
+		// Include all of the end field.
+		eword++
+	}
+	for p < lim && eword > 0 {
+		eword--
+		if tab >= 0 {
+			for p < lim && int(text[p]) != tab {
+				p++
+			}
+			if p < lim && (eword > 0 || echar != 0) {
+				p++
+			}
+		} else {
+			p = skipBlanks(text, p)
+			p = skipNonBlanks(text, p)
+		}
+	}
+	if echar != 0 {
+		if k.skipeblanks {
+			p = skipBlanks(text, p)
+		}
+		p = min(lim, p+echar)
+	}
+	return p
+}
+
+// compare compares the texts of two keys, not taking k.reverse into
+// account.
+func (k *keyDef) compare(a, b []byte) int {
+	if k.numeric {
+		return numCompare(a, b)
+	}
+	return bytes.Compare(a, b)
+}
+
+// isBlank reports whether c separates fields, as in GNU sort.
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func skipBlanks(text []byte, p int) int {
+	for p < len(text) && isBlank(text[p]) {
+		p++
+	}
+	return p
+}
+
+func skipNonBlanks(text []byte, p int) int {
+	for p < len(text) && !isBlank(text[p]) {
+		p++
+	}
+	return p
+}
+
+// numCompare compares the numbers at the start of a and b, ignoring leading
+// blanks, as GNU sort -n does in the C locale. A number is an optional minus
+// sign, digits, and an optional decimal point followed by digits. Text that
+// does not start with a number compares equal to zero. Numbers of any length
+// are compared exactly.
+func numCompare(a, b []byte) int {
+	a = a[skipBlanks(a, 0):]
+	b = b[skipBlanks(b, 0):]
+	n := numScanner{a: a, b: b}
+	return n.compare()
+}
+
+// A numScanner walks two numbers in parallel. It follows the structure of
+// strnumcmp in gnulib, which GNU sort uses, reading past the end of a number
+// as a NUL byte.
+type numScanner struct {
+	a, b []byte
+	i, j int // positions in a and b
+}
+
+// ca and cb return the current bytes of a and b, or 0 at the end.
+func (n *numScanner) ca() byte { return at(n.a, n.i) }
+func (n *numScanner) cb() byte { return at(n.b, n.j) }
+
+func at(s []byte, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+// skipA advances past the current byte of a and any following zeros.
+func (n *numScanner) skipA() {
+	n.i++
+	for n.ca() == '0' {
+		n.i++
+	}
+}
+
+func (n *numScanner) skipB() {
+	n.j++
+	for n.cb() == '0' {
+		n.j++
+	}
+}
+
+// digitsA and digitsB count the digits starting at the current positions.
+func (n *numScanner) digitsA() int {
+	d := 0
+	for isDigit(n.ca()) {
+		n.i++
+		d++
+	}
+	return d
+}
+
+func (n *numScanner) digitsB() int {
+	d := 0
+	for isDigit(n.cb()) {
+		n.j++
+		d++
+	}
+	return d
+}
+
+func (n *numScanner) compare() int {
+	switch {
+	case n.ca() == '-' && n.cb() != '-':
+		// a is negative or zero, b is non-negative.
+		n.skipA()
+		if n.ca() == '.' {
+			n.skipA()
+		}
+		if isDigit(n.ca()) {
+			return -1
+		}
+		n.j--
+		n.skipB()
+		if n.cb() == '.' {
+			n.skipB()
+		}
+		return -b2i(isDigit(n.cb()))
+	case n.cb() == '-' && n.ca() != '-':
+		n.skipB()
+		if n.cb() == '.' {
+			n.skipB()
+		}
+		if isDigit(n.cb()) {
+			return 1
+		}
+		n.i--
+		n.skipA()
+		if n.ca() == '.' {
+			n.skipA()
+		}
+		return b2i(isDigit(n.ca()))
+	case n.ca() == '-':
+		// Both are negative: compare magnitudes in reverse.
+		n.skipA()
+		n.skipB()
+		return -n.compareMagnitudes()
+	default:
+		n.i--
+		n.skipA()
+		n.j--
+		n.skipB()
+		return n.compareMagnitudes()
+	}
+}
+
+// compareMagnitudes compares two unsigned numbers whose leading zeros have
+// been skipped.
+func (n *numScanner) compareMagnitudes() int {
+	for n.ca() == n.cb() && isDigit(n.ca()) {
+		n.i++
+		n.j++
+	}
+	ca, cb := n.ca(), n.cb()
+	if (ca == '.' && !isDigit(cb)) || (cb == '.' && !isDigit(ca)) {
+		return fracCompare(n.a[n.i:], n.b[n.j:])
+	}
+	diff := int(ca) - int(cb)
+	la, lb := n.digitsA(), n.digitsB()
+	switch {
+	case la != lb:
+		return b2i(la > lb) - b2i(la < lb)
+	case la == 0:
+		return 0
+	}
+	return diff
+}
+
+// fracCompare compares the fractional parts at the start of a and b, one
+// or both of which start with a decimal point.
+func fracCompare(a, b []byte) int {
+	i, j := 0, 0
+	switch {
+	case at(a, 0) == '.' && at(b, 0) == '.':
+		for {
+			i++
+			j++
+			if at(a, i) != at(b, j) {
+				break
+			}
+			if !isDigit(at(a, i)) {
+				return 0
+			}
+		}
+		ca, cb := at(a, i), at(b, j)
+		switch {
+		case isDigit(ca) && isDigit(cb):
+			return int(ca) - int(cb)
+		case isDigit(ca):
+			return b2i(hasNonzero(a[i:]))
+		case isDigit(cb):
+			return -b2i(hasNonzero(b[j:]))
+		}
+		return 0
+	case at(a, 0) == '.':
+		return b2i(hasNonzero(a[1:]))
+	case at(b, 0) == '.':
+		return -b2i(hasNonzero(b[1:]))
+	}
+	return 0
+}
+
+// hasNonzero reports whether the digits at the start of s, after any
+// zeros, include a nonzero digit.
+func hasNonzero(s []byte) bool {
+	i := 0
+	for at(s, i) == '0' {
+		i++
+	}
+	return isDigit(at(s, i))
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,257 @@
+// Heapsort sorts or merges files that may be larger than memory.
+//
+// Usage:
+//
+//	heapsort [-bmnrsu] [-k keydef]... [-t sep] [-S size] [-T dir] [--format fmt] [file ...]
+//
+// Heapsort writes the sorted concatenation of the files to standard output.
+// With no files, or when a file is "-", it reads standard input.
+//
+// Heapsort reads records until they fill its memory limit, sorts them with
+// a [heap.Heap], and writes them to a temporary file, called a run. It then
+// merges the runs with [heap.Merge]. If the input fits in memory, it is
+// sorted without temporary files.
+//
+// For the flags it supports, heapsort behaves like GNU sort in the C locale,
+// and its output is identical to that of
+//
+//	LC_ALL=C sort [flags] [file ...]
+//
+// The flags are:
+//
+//	-b       ignore leading blanks in keys
+//	-k def   sort by a key; see below. The flag may be repeated.
+//	-m       merge files that are already sorted, instead of sorting them
+//	-n       compare keys as decimal numbers
+//	-r       reverse the order
+//	-s       stable sort: keep records with equal keys in input order,
+//	         instead of comparing them in full
+//	-S size  memory limit, as a number of kibibytes or with a suffix of
+//	         b, K, M, G or T; the default is 256M
+//	-t sep   separate fields by the character sep, instead of by the
+//	         change from a non-blank to a blank character
+//	-T dir   create temporary files in dir, instead of the system default
+//	-u       output only the first of records with equal keys
+//
+// A key definition for lines has the form F[.C][opts][,F[.C][opts]], as for
+// GNU sort: the key starts at character C (default 1) of field F, and ends at
+// character C (default the last) of the second field F (default the end of
+// the line). The opts are any of the letters b, n and r, which apply the
+// flags of the same name to that key alone. A key with no options of its own
+// uses the global -b, -n and -r flags.
+//
+// The --format flag selects how the input is divided into records and fields:
+//
+//	lines  each line is a record, divided into fields as described above
+//	       (the default)
+//	tsv    like lines, but fields are separated by tabs by default
+//	csv    each record is a line of comma-separated values as described in
+//	       RFC 4180, which may span several lines if a field is quoted.
+//	       Key definitions refer to the decoded fields. The -t flag sets
+//	       the separator.
+//	jsonl  each line is a JSON object. A key definition has the form
+//	       path[:opts], where path names a field, with dots separating the
+//	       names of nested fields. The key is the field's value: the text
+//	       of a string, the literal text of a number, and the compact JSON
+//	       encoding of other values. A missing field or null is empty.
+//
+// Records are written as they were read, each followed by a newline.
+// Heapsort exits with status 2 if an error occurs.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const usage = `usage: heapsort [-bmnrsu] [-k keydef]... [-t sep] [-S size] [-T dir] [--format fmt] [file ...]
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "heapsort: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+var errUsage = errors.New("usage")
+
+// run runs heapsort with the given command-line arguments.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	s, files, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+	s.stdin = stdin
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	w := bufio.NewWriter(stdout)
+	if s.presorted {
+		err = s.mergeFiles(files, w)
+	} else {
+		err = s.sortFiles(files, w)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// parseArgs parses the command line in the manner of getopt, as GNU sort
+// does, so that flags can be combined and their values attached, as in
+// "-nr" and "-k2,2". It returns a sorter and the names of the input files.
+func parseArgs(args []string) (*sorter, []string, error) {
+	s := &sorter{tab: -1, memLimit: 256 << 20}
+	var global keyDef
+	var keys []string
+	format := "lines"
+	sep := ""
+	var files []string
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		switch {
+		case arg == "--":
+			files = append(files, args...)
+			args = nil
+			continue
+		case arg == "--help" || arg == "-h":
+			return nil, nil, errUsage
+		case strings.HasPrefix(arg, "--format"):
+			if v, ok := strings.CutPrefix(arg, "--format="); ok {
+				format = v
+			} else if arg == "--format" && len(args) > 0 {
+				format, args = args[0], args[1:]
+			} else {
+				return nil, nil, errUsage
+			}
+			continue
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			files = append(files, arg)
+			continue
+		}
+		for i := 1; i < len(arg); i++ {
+			c := arg[i]
+			switch c {
+			case 'b':
+				global.skipsblanks, global.skipeblanks = true, true
+			case 'n':
+				global.numeric = true
+			case 'r':
+				global.reverse = true
+			case 'm':
+				s.presorted = true
+			case 's':
+				s.stable = true
+			case 'u':
+				s.unique = true
+			case 'k', 't', 'S', 'T':
+				v := arg[i+1:]
+				if v == "" {
+					if len(args) == 0 {
+						return nil, nil, fmt.Errorf("option requires an argument -- '%c'", c)
+					}
+					v, args = args[0], args[1:]
+				}
+				switch c {
+				case 'k':
+					keys = append(keys, v)
+				case 't':
+					sep = v
+				case 'S':
+					n, err := parseSize(v)
+					if err != nil {
+						return nil, nil, err
+					}
+					s.memLimit = n
+				case 'T':
+					s.tempDir = v
+				}
+				i = len(arg)
+			default:
+				return nil, nil, fmt.Errorf("invalid option -- '%c'\n%s", c, usage)
+			}
+		}
+	}
+
+	switch format {
+	case "lines":
+	case "tsv":
+		s.tab = '\t'
+	case "csv":
+		s.csvComma = ','
+		s.tab = csvFieldSep
+	case "jsonl":
+		s.json = true
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q", format)
+	}
+	if sep != "" {
+		if sep == `\0` {
+			sep = "\x00"
+		}
+		if s.csvComma != 0 {
+			r, n := utf8.DecodeRuneInString(sep)
+			if n != len(sep) {
+				return nil, nil, fmt.Errorf("multi-character separator %q", sep)
+			}
+			s.csvComma = r
+		} else {
+			if len(sep) != 1 {
+				return nil, nil, fmt.Errorf("multi-character tab %q", sep)
+			}
+			s.tab = int(sep[0])
+		}
+	}
+
+	for _, def := range keys {
+		var k *keyDef
+		var err error
+		if s.json {
+			k, err = parseJSONKey(def)
+		} else {
+			k, err = parseKey(def)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		s.keys = append(s.keys, k)
+	}
+	s.setGlobal(global)
+	return s, files, nil
+}
+
+// parseSize parses the argument of the -S flag.
+func parseSize(arg string) (int64, error) {
+	mult := int64(1 << 10)
+	num := arg
+	if n := len(arg); n > 0 {
+		if i := strings.IndexByte("bKMGT", upper(arg[n-1])); i >= 0 {
+			mult = []int64{1, 1 << 10, 1 << 20, 1 << 30, 1 << 40}[i]
+			num = arg[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/mult {
+		return 0, fmt.Errorf("invalid -S argument %q", arg)
+	}
+	return max(n*mult, 1), nil
+}
+
+func upper(c byte) byte {
+	if 'a' <= c && c <= 'z' && c != 'b' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
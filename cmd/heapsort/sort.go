@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"io"
+	"iter"
+	"os"
+
+	"github.com/jba/heap"
+)
+
+// A sorter sorts and merges records.
+type sorter struct {
+	keys      []*keyDef
+	tab       int  // field separator for keys, or -1 to separate fields by blanks
+	csvComma  rune // the CSV separator, or 0 if the input is not CSV
+	json      bool // whether the input is JSON lines
+	reverse   bool // reverse the comparison of whole records
+	stable    bool
+	unique    bool
+	presorted bool // the inputs are already sorted, so merge them
+	memLimit  int64
+	tempDir   string
+	stdin     io.Reader
+
+	dir  string          // directory for runs, or "" if not yet created
+	runs map[string]bool // names of runs not yet removed
+}
+
+// maxMergeFiles is the maximum number of files merged at once.
+// Merging more files takes several passes.
+const maxMergeFiles = 32
+
+// compare compares two records as GNU sort does: by their keys in order,
+// and then, unless the sort is stable or unique, by their full text.
+func (s *sorter) compare(a, b *record) int {
+	for i, k := range s.keys {
+		if c := k.compare(a.keys[i], b.keys[i]); c != 0 {
+			if k.reverse {
+				return -c
+			}
+			return c
+		}
+	}
+	if len(s.keys) > 0 && (s.stable || s.unique) {
+		return 0
+	}
+	c := bytes.Compare(a.line, b.line)
+	if s.reverse {
+		return -c
+	}
+	return c
+}
+
+// compareSeq is like compare, but orders records that compare equal by
+// their position in the input.
+func (s *sorter) compareSeq(a, b *record) int {
+	if c := s.compare(a, b); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.seq, b.seq)
+}
+
+// sortFiles sorts the records in the named files and writes them to w.
+// It sorts as many records as fit within the memory limit with a heap.
+// If they do not all fit, it writes each heapful to a run and merges the
+// runs.
+func (s *sorter) sortFiles(names []string, w *bufio.Writer) error {
+	defer s.cleanup()
+	var (
+		chunk []*record
+		size  int64
+		seq   int
+		runs  []string
+	)
+	spill := func() error {
+		run, err := s.writeRun(s.sorted(chunk))
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+		// The heap owns the old chunk.
+		chunk, size = nil, 0
+		return nil
+	}
+	for _, name := range names {
+		err := s.readFile(name, func(r *record) error {
+			r.seq = seq
+			seq++
+			chunk = append(chunk, r)
+			size += r.size
+			if size >= s.memLimit {
+				return spill()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(runs) == 0 {
+		return s.write(w, s.sorted(chunk))
+	}
+	if len(chunk) > 0 {
+		if err := spill(); err != nil {
+			return err
+		}
+	}
+	return s.mergeFiles(runs, w)
+}
+
+// sorted returns the records in sorted order, using Heap.Init and Drain.
+// Records that compare equal are kept in input order.
+func (s *sorter) sorted(records []*record) iter.Seq[*record] {
+	h := heap.New(s.compareSeq)
+	h.Init(records)
+	return h.Drain()
+}
+
+// mergeFiles merges the records in the named files, which must be sorted,
+// and writes them to w. If there are too many files to merge at once, it
+// merges the first ones into a run, and repeats.
+func (s *sorter) mergeFiles(names []string, w *bufio.Writer) error {
+	defer s.cleanup()
+	for len(names) > maxMergeFiles {
+		var run string
+		err := s.merge(names[:maxMergeFiles], func(recs iter.Seq[*record]) error {
+			var err error
+			run, err = s.writeRun(recs)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// The merged run takes the place of its inputs, which come first,
+		// to preserve the order of equal records.
+		names = append([]string{run}, names[maxMergeFiles:]...)
+	}
+	return s.merge(names, func(recs iter.Seq[*record]) error {
+		return s.write(w, recs)
+	})
+}
+
+// merge calls f with the merged records of the named files, and then
+// removes the files that are runs.
+func (s *sorter) merge(names []string, f func(iter.Seq[*record]) error) error {
+	var readErr error
+	seqs := make([]iter.Seq[*record], len(names))
+	for i, name := range names {
+		r, err := s.open(name)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		seqs[i] = s.records(s.newReader(r, name), &readErr)
+	}
+	// heap.Merge breaks ties by the order of the files, which is the order
+	// of the input.
+	if err := f(heap.Merge(s.compare, seqs...)); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	for _, name := range names {
+		if s.runs[name] {
+			delete(s.runs, name)
+			if err := os.Remove(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// records returns the records read by r. If reading fails, the sequence
+// ends early and the error is stored in *errp, unless it holds one already.
+func (s *sorter) records(r recordReader, errp *error) iter.Seq[*record] {
+	return func(yield func(*record) bool) {
+		for {
+			rec, err := r.next()
+			if err != nil {
+				if err != io.EOF && *errp == nil {
+					*errp = err
+				}
+				return
+			}
+			if !yield(rec) {
+				return
+			}
+		}
+	}
+}
+
+// readFile calls f with each record in the named file.
+func (s *sorter) readFile(name string, f func(*record) error) error {
+	file, err := s.open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r := s.newReader(file, name)
+	for {
+		rec, err := r.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// open opens the named file, or standard input if name is "-".
+func (s *sorter) open(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(s.stdin), nil
+	}
+	return os.Open(name)
+}
+
+// write writes records to w. If the sort is unique, it writes only the
+// first of each group of equal records.
+func (s *sorter) write(w *bufio.Writer, recs iter.Seq[*record]) error {
+	var prev *record
+	for r := range recs {
+		if s.unique && prev != nil && s.compare(prev, r) == 0 {
+			continue
+		}
+		w.Write(r.line)
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+		prev = r
+	}
+	return nil
+}
+
+// writeRun writes records to a new run and returns its name.
+func (s *sorter) writeRun(recs iter.Seq[*record]) (string, error) {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp(s.tempDir, "heapsort-")
+		if err != nil {
+			return "", err
+		}
+		s.dir = dir
+		s.runs = map[string]bool{}
+	}
+	f, err := os.CreateTemp(s.dir, "run-")
+	if err != nil {
+		return "", err
+	}
+	s.runs[f.Name()] = true
+	w := bufio.NewWriter(f)
+	err = s.write(w, recs)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return f.Name(), err
+}
+
+// cleanup removes the runs.
+func (s *sorter) cleanup() {
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A record is a unit of input.
+type record struct {
+	line   []byte   // the text of a line, without its newline
+	fields []string // the fields of a csv record
+	key    string   // the value of the key field
+	group  string   // the value of the group field
+}
+
+// An input reads records in one of the supported formats.
+type input struct {
+	format     string
+	delim      string // for lines; "" to split fields at blanks
+	comma      rune   // for csv
+	header     bool
+	headerRec  []string
+	key, group selector
+	grouped    bool // whether there is a group field
+	invalid    int  // number of invalid records skipped
+}
+
+// A selector names a field.
+type selector struct {
+	index int      // for lines and csv: the field number; 0 for the whole record
+	name  string   // for csv: a column name, resolved to index by the header
+	path  []string // for jsonl: the path of the field; nil for the whole record
+}
+
+func newInput(c *config) (*input, error) {
+	in := &input{format: c.format, header: c.header}
+	switch c.format {
+	case "lines":
+		in.delim = c.delim
+	case "csv":
+		in.comma = ','
+		if c.delim != "" {
+			r, n := utf8.DecodeRuneInString(c.delim)
+			if n != len(c.delim) {
+				return nil, fmt.Errorf("invalid csv delimiter %q", c.delim)
+			}
+			in.comma = r
+		}
+	case "jsonl":
+	default:
+		return nil, fmt.Errorf("unknown format %q", c.format)
+	}
+	if c.header && c.format != "csv" {
+		return nil, errors.New("-header requires -format csv")
+	}
+	var err error
+	if in.key, err = in.parseSelector(c.key); err != nil {
+		return nil, fmt.Errorf("-f: %v", err)
+	}
+	if in.group, err = in.parseSelector(c.group); err != nil {
+		return nil, fmt.Errorf("-g: %v", err)
+	}
+	in.grouped = c.group != ""
+	return in, nil
+}
+
+func (in *input) parseSelector(s string) (selector, error) {
+	if s == "" {
+		return selector{}, nil
+	}
+	if in.format == "jsonl" {
+		return selector{path: strings.Split(s, ".")}, nil
+	}
+	n, err := strconv.Atoi(s)
+	switch {
+	case err == nil && n >= 0 && (n > 0 || in.format == "lines"):
+		return selector{index: n}, nil
+	case err == nil:
+		return selector{}, fmt.Errorf("invalid field number %d", n)
+	case in.header:
+		return selector{name: s}, nil
+	}
+	return selector{}, fmt.Errorf("invalid field %q", s)
+}
+
+// read calls add with each record read from r.
+func (in *input) read(r io.Reader, name string, add func(*record)) error {
+	if in.format == "csv" {
+		return in.readCSV(r, name, add)
+	}
+	br := bufio.NewReaderSize(r, 64<<10)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(line, []byte("\n"))
+			var rec *record
+			if in.format == "jsonl" {
+				rec = in.jsonRecord(line)
+			} else {
+				rec = &record{line: line, key: in.field(line, in.key.index)}
+				if in.grouped {
+					rec.group = in.field(line, in.group.index)
+				}
+			}
+			if rec != nil {
+				add(rec)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// field returns the nth field of line, or the whole line if n is 0.
+// It returns "" if the line has fewer than n fields.
+func (in *input) field(line []byte, n int) string {
+	if n == 0 {
+		return string(line)
+	}
+	if in.delim != "" {
+		for f := range bytes.SplitSeq(line, []byte(in.delim)) {
+			if n--; n == 0 {
+				return string(f)
+			}
+		}
+		return ""
+	}
+	for f := range bytes.FieldsSeq(line) {
+		if n--; n == 0 {
+			return string(f)
+		}
+	}
+	return ""
+}
+
+// jsonRecord returns the record for a line of JSON, or nil if the line is
+// not valid JSON.
+func (in *input) jsonRecord(line []byte) *record {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		in.invalid++
+		return nil
+	}
+	rec := &record{line: line, key: jsonField(line, v, in.key.path)}
+	if in.grouped {
+		rec.group = jsonField(line, v, in.group.path)
+	}
+	return rec
+}
+
+// jsonField returns the text of the field of v at path, or the whole line
+// if path is nil. A missing field or null is empty.
+func jsonField(line []byte, v any, path []string) string {
+	if path == nil {
+		return string(line)
+	}
+	for _, name := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = m[name]
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func (in *input) readCSV(r io.Reader, name string, add func(*record)) error {
+	cr := csv.NewReader(r)
+	cr.Comma = in.comma
+	cr.FieldsPerRecord = -1
+	first := true
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if first && in.header {
+			first = false
+			if in.headerRec == nil {
+				in.headerRec = fields
+				if err := in.resolve(&in.key); err != nil {
+					return err
+				}
+				if err := in.resolve(&in.group); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		rec := &record{
+			fields: fields,
+			key:    csvField(fields, in.key.index, in.comma),
+		}
+		if in.grouped {
+			rec.group = csvField(fields, in.group.index, in.comma)
+		}
+		add(rec)
+	}
+}
+
+// resolve sets the index of a selector that names a column of the header.
+func (in *input) resolve(s *selector) error {
+	if s.name == "" {
+		return nil
+	}
+	for i, h := range in.headerRec {
+		if h == s.name {
+			s.index = i + 1
+			return nil
+		}
+	}
+	return fmt.Errorf("no column named %q", s.name)
+}
+
+// csvField returns the nth field, counting from 1, or all the fields
+// joined by comma if n is 0.
+func csvField(fields []string, n int, comma rune) string {
+	if n == 0 {
+		return strings.Join(fields, string(comma))
+	}
+	if n > len(fields) {
+		return ""
+	}
+	return fields[n-1]
+}
+
+// write writes the records to w.
+func (in *input) write(w io.Writer, recs []*record) error {
+	if in.format == "csv" {
+		cw := csv.NewWriter(w)
+		cw.Comma = in.comma
+		if in.headerRec != nil {
+			cw.Write(in.headerRec)
+		}
+		for _, r := range recs {
+			cw.Write(r.fields)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	bw := bufio.NewWriter(w)
+	for _, r := range recs {
+		bw.Write(r.line)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
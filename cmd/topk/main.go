@@ -0,0 +1,132 @@
+// Topk prints the K records with the largest or smallest keys in its input.
+//
+// Usage:
+//
+//	topk [flags] [file ...]
+//
+// Topk reads the files in order, or standard input if there are none, and
+// prints the K records with the largest keys, largest first. It holds only
+// K records in memory at a time, in a [heap.Heap] whose minimum is the
+// smallest of the records kept so far. A record that is larger than that
+// minimum replaces it with [heap.Heap.ChangeMin]; any other record is
+// discarded. Records with equal keys are kept and printed in input order.
+//
+// The flags are:
+//
+//	-k n       print n records (default 10)
+//	-f field   the key field (default the whole record)
+//	-n         compare keys as numbers instead of as strings
+//	-min       print the records with the smallest keys, smallest first
+//	-ties      also print the records whose keys equal that of the last
+//	           record printed, so that more than n records may be printed
+//	-g field   print the top n records in each group of records with the
+//	           same value of field. Groups are printed in the order in
+//	           which they first appear.
+//	-format f  the input format: lines (default), csv or jsonl
+//	-d delim   the field delimiter for lines and csv (default blanks for
+//	           lines, and a comma for csv)
+//	-header    the first record of each csv file is a header; the first
+//	           header is printed first, and fields may be named by it
+//
+// How a field is named depends on the format. For lines, a field is a
+// number, counting from 1, of a field separated by the delimiter, or by
+// runs of blanks if there is none; field 0 is the whole line. For csv, a
+// field is a column number counting from 1, or a name from the header.
+// For jsonl, a field is the name of a field of the JSON object on each
+// line, with dots separating the names of nested fields; its value is the
+// text of a string, the literal text of a number, or the compact JSON
+// encoding of another value.
+//
+// With -n, records whose keys are not numbers are skipped, as are lines
+// of jsonl input that are not valid JSON. Topk reports the number of
+// records it skipped on standard error.
+//
+// Lines and JSON lines are printed as they were read; csv records are
+// printed in canonical CSV form.
+//
+// For example, to print the 20 slowest requests in a JSON log, by path:
+//
+//	topk -format jsonl -k 20 -n -f latency_ms -g path requests.log
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "topk: %v\n", err)
+		}
+		os.Exit(2)
+	}
+}
+
+// run runs topk with the given command-line arguments.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("topk", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var c config
+	fs.IntVar(&c.k, "k", 10, "print `n` records")
+	fs.StringVar(&c.key, "f", "", "the key `field`")
+	fs.BoolVar(&c.numeric, "n", false, "compare keys as numbers")
+	fs.BoolVar(&c.smallest, "min", false, "print the records with the smallest keys")
+	fs.BoolVar(&c.ties, "ties", false, "also print records that tie with the last one")
+	fs.StringVar(&c.group, "g", "", "print the top records in each group with the same `field`")
+	fs.StringVar(&c.format, "format", "lines", "input `format`: lines, csv or jsonl")
+	fs.StringVar(&c.delim, "d", "", "field `delimiter` for lines and csv")
+	fs.BoolVar(&c.header, "header", false, "the first csv record is a header")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: topk [flags] [file ...]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.k < 0 {
+		return errors.New("-k must not be negative")
+	}
+	in, err := newInput(&c)
+	if err != nil {
+		return err
+	}
+
+	t := newTopK(&c)
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		if err := readFile(name, stdin, in, t); err != nil {
+			return err
+		}
+	}
+	if err := in.write(stdout, t.results()); err != nil {
+		return err
+	}
+	if t.skipped > 0 {
+		fmt.Fprintf(stderr, "topk: skipped %d records without a numeric key\n", t.skipped)
+	}
+	if in.invalid > 0 {
+		fmt.Fprintf(stderr, "topk: skipped %d invalid records\n", in.invalid)
+	}
+	return nil
+}
+
+// readFile adds the records of the named file, or of stdin if the name is
+// "-", to t.
+func readFile(name string, stdin io.Reader, in *input, t *topK) error {
+	if name == "-" {
+		return in.read(stdin, name, t.add)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return in.read(f, name, t.add)
+}
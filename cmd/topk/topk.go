@@ -0,0 +1,138 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jba/heap"
+)
+
+// config holds the command-line flags.
+type config struct {
+	k        int
+	key      string
+	group    string
+	numeric  bool
+	smallest bool
+	ties     bool
+	format   string
+	delim    string
+	header   bool
+}
+
+// An item is a record being ranked.
+type item struct {
+	rec *record
+	num float64 // the key as a number, if the comparison is numeric
+	seq int     // position in the input, to keep equal records in order
+}
+
+// A topK keeps the best records of each group.
+type topK struct {
+	c       *config
+	rank    func(a, b *item) int // positive if a ranks above b, by key alone
+	groups  map[string]*group
+	order   []string // group names, in order of appearance
+	seq     int
+	skipped int // records without a numeric key
+}
+
+// A group holds the best records with the same value of the group field.
+type group struct {
+	// h holds the k best records seen so far. Its minimum is the worst
+	// of them: the one with the lowest rank, or the latest in the input
+	// among those with the lowest rank.
+	h *heap.Heap[*item]
+	// ties holds the records not in h whose rank equals that of the
+	// minimum of h, if ties are kept.
+	ties []*item
+}
+
+func newTopK(c *config) *topK {
+	t := &topK{c: c, groups: map[string]*group{}}
+	if c.numeric {
+		t.rank = func(a, b *item) int { return cmp.Compare(a.num, b.num) }
+	} else {
+		t.rank = func(a, b *item) int { return strings.Compare(a.rec.key, b.rec.key) }
+	}
+	if c.smallest {
+		rank := t.rank
+		t.rank = func(a, b *item) int { return rank(b, a) }
+	}
+	return t
+}
+
+// add considers a record for the top K of its group.
+func (t *topK) add(r *record) {
+	it := &item{rec: r, seq: t.seq}
+	t.seq++
+	if t.c.numeric {
+		n, err := strconv.ParseFloat(strings.TrimSpace(r.key), 64)
+		if err != nil {
+			t.skipped++
+			return
+		}
+		it.num = n
+	}
+	g := t.groups[r.group]
+	if g == nil {
+		g = &group{h: heap.New(func(a, b *item) int {
+			if c := t.rank(a, b); c != 0 {
+				return c
+			}
+			return cmp.Compare(b.seq, a.seq)
+		})}
+		t.groups[r.group] = g
+		t.order = append(t.order, r.group)
+	}
+	t.addToGroup(g, it)
+}
+
+// addToGroup adds it to g if it ranks among the best k items. This is the
+// top K algorithm: once the heap is full, an item that ranks above the
+// minimum replaces it.
+func (t *topK) addToGroup(g *group, it *item) {
+	if t.c.k == 0 {
+		return
+	}
+	if g.h.Len() < t.c.k {
+		g.h.Insert(it)
+		return
+	}
+	min := g.h.Min()
+	switch c := t.rank(it, min); {
+	case c == 0 && t.c.ties:
+		g.ties = append(g.ties, it)
+	case c > 0:
+		g.h.ChangeMin(it)
+		if !t.c.ties {
+			break
+		}
+		if t.rank(min, g.h.Min()) == 0 {
+			// The boundary has not moved, so the old minimum still ties.
+			g.ties = append(g.ties, min)
+		} else {
+			g.ties = g.ties[:0]
+		}
+	}
+}
+
+// results returns the best records of each group, best first, with the
+// groups in order of appearance.
+func (t *topK) results() []*record {
+	var recs []*record
+	for _, name := range t.order {
+		g := t.groups[name]
+		for _, it := range g.ties {
+			g.h.Insert(it)
+		}
+		items := slices.Collect(g.h.Drain())
+		slices.Reverse(items)
+		for _, it := range items {
+			recs = append(recs, it.rec)
+		}
+	}
+	return recs
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func runTopK(t *testing.T, args, input string) (stdout, stderr string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	if err := run(strings.Fields(args), strings.NewReader(input), &out, &errOut); err != nil {
+		t.Fatalf("topk %s: %v", args, err)
+	}
+	return out.String(), errOut.String()
+}
+
+func TestTopK(t *testing.T) {
+	const lines = "a 5\nb 9\nc 5\nd 1\ne 9\nf 5\n"
+	for _, test := range []struct {
+		args  string
+		input string
+		want  string
+	}{
+		{"-k 2 -f 2 -n", lines, "b 9\ne 9\n"},
+		{"-k 3 -f 2 -n", lines, "b 9\ne 9\na 5\n"},
+		{"-k 3 -f 2 -n -ties", lines, "b 9\ne 9\na 5\nc 5\nf 5\n"},
+		{"-k 2 -f 2 -n -ties", lines, "b 9\ne 9\n"},
+		{"-k 2 -f 2 -n -min", lines, "d 1\na 5\n"},
+		{"-k 2 -f 2 -n -min -ties", lines, "d 1\na 5\nc 5\nf 5\n"},
+		{"-k 0 -f 2 -ties", lines, ""},
+		{"-k 10 -f 2 -n", lines, "b 9\ne 9\na 5\nc 5\nf 5\nd 1\n"},
+		// Lexical comparison of the whole line.
+		{"-k 2", "b\nab\nc\n", "c\nb\n"},
+		{"-k 2 -min", "b\nab\nc\n", "ab\nb\n"},
+		// Numbers compare by value, and strings by bytes.
+		{"-k 1 -f 1 -n", "9\n10\n", "10\n"},
+		{"-k 1 -f 1", "9\n10\n", "9\n"},
+		// A delimiter, and a missing field.
+		{"-k 2 -d : -f 3", "a:b:z\nc:d\ne:f:y\n", "a:b:z\ne:f:y\n"},
+		{"-k 2 -d : -f 3 -min", "a:b:z\nc:d\ne:f:y\n", "c:d\ne:f:y\n"},
+		// Groups.
+		{
+			"-k 1 -f 2 -n -g 1",
+			"x 1\ny 5\nx 3\ny 2\nz 0\nx 2\n",
+			"x 3\ny 5\nz 0\n",
+		},
+		{
+			"-k 2 -f 2 -n -g 1 -min -ties",
+			"x 1\ny 5\nx 3\ny 2\nz 0\nx 1\nx 3\n",
+			"x 1\nx 1\ny 2\ny 5\nz 0\n",
+		},
+		// CSV, with names from the header.
+		{
+			"-format csv -header -k 2 -f latency -n",
+			"path,latency\n/a,10\n\"/b,c\",30\n/d,20\n",
+			"path,latency\n\"/b,c\",30\n/d,20\n",
+		},
+		{
+			"-format csv -d ; -k 1 -f 2 -g 1",
+			"a;x\nb;y\na;z\n",
+			"a;z\nb;y\n",
+		},
+		// JSON lines, with a nested field.
+		{
+			"-format jsonl -k 1 -f req.ms -n -g req.path",
+			`{"req":{"path":"/a","ms":10}}` + "\n" +
+				`{"req":{"path":"/b","ms":5}}` + "\n" +
+				`{"req":{"path":"/a","ms":12.5}}` + "\n",
+			`{"req":{"path":"/a","ms":12.5}}` + "\n" +
+				`{"req":{"path":"/b","ms":5}}` + "\n",
+		},
+	} {
+		got, _ := runTopK(t, test.args, test.input)
+		if got != test.want {
+			t.Errorf("topk %s:\ngot\n%s\nwant\n%s", test.args, got, test.want)
+		}
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	out, errOut := runTopK(t, "-format jsonl -k 5 -f ms -n", `{"ms":3}`+"\n"+`{"ms":"fast"}`+"\n"+"{\n"+`{}`+"\n")
+	if want := `{"ms":3}` + "\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	for _, want := range []string{"skipped 2 records without a numeric key", "skipped 1 invalid records"} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("stderr %q does not contain %q", errOut, want)
+		}
+	}
+}
+
+func TestBadArgs(t *testing.T) {
+	for _, args := range []string{
+		"-k -1",
+		"-format xml",
+		"-f x",
+		"-f 0 -format csv",
+		"-header",
+		"-format csv -d ab",
+		"-format csv -header -f missing",
+	} {
+		var out, errOut bytes.Buffer
+		err := run(strings.Fields(args), strings.NewReader("a,b\n1,2\n"), &out, &errOut)
+		if err == nil {
+			t.Errorf("topk %s: succeeded, want error", args)
+		}
+	}
+}
+
+// TestRandom compares topk with sorting all the records.
+func TestRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 200 {
+		k := r.IntN(6)
+		var input strings.Builder
+		type rec struct {
+			line       string
+			group, key int
+			seq        int
+		}
+		var recs []rec
+		for i := range r.IntN(50) {
+			g, key := r.IntN(3), r.IntN(10)
+			line := fmt.Sprintf("r%d %d %d", i, g, key)
+			recs = append(recs, rec{line, g, key, i})
+			input.WriteString(line + "\n")
+		}
+		smallest, ties := r.IntN(2) == 0, r.IntN(2) == 0
+		args := fmt.Sprintf("-k %d -f 3 -n -g 2", k)
+		if smallest {
+			args += " -min"
+		}
+		if ties {
+			args += " -ties"
+		}
+
+		// Sort by group in order of appearance, then best first, then by
+		// input order.
+		firstSeen := map[int]int{}
+		for _, rc := range recs {
+			if _, ok := firstSeen[rc.group]; !ok {
+				firstSeen[rc.group] = rc.seq
+			}
+		}
+		slices.SortFunc(recs, func(a, b rec) int {
+			c := cmp.Compare(firstSeen[a.group], firstSeen[b.group])
+			if c == 0 {
+				c = cmp.Compare(b.key, a.key)
+				if smallest {
+					c = -c
+				}
+			}
+			return cmp.Or(c, cmp.Compare(a.seq, b.seq))
+		})
+		var want strings.Builder
+		for i := 0; i < len(recs); {
+			j := i
+			for j < len(recs) && recs[j].group == recs[i].group {
+				j++
+			}
+			group := recs[i:j]
+			n := min(k, len(group))
+			if ties && n > 0 {
+				for n < len(group) && group[n].key == group[n-1].key {
+					n++
+				}
+			}
+			for _, rc := range group[:n] {
+				want.WriteString(rc.line + "\n")
+			}
+			i = j
+		}
+		if got, _ := runTopK(t, args, input.String()); got != want.String() {
+			t.Fatalf("topk %s\ninput:\n%s\ngot\n%s\nwant\n%s", args, input.String(), got, want.String())
+		}
+	}
+}
+
+func TestNumericKey(t *testing.T) {
+	// Keys may have surrounding spaces, and exponents.
+	out, _ := runTopK(t, "-d , -k 1 -f 1 -n", " 2e3 ,a\n999,b\n")
+	if want := " 2e3 ,a\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,200 @@
+// Package compat helps programs move between container/heap and
+// [github.com/jba/heap].
+//
+// [Wrap] lets code written against a [heap.Heap] use an existing
+// implementation of [container/heap.Interface]. The functions [Init], [Push],
+// [Pop], [Fix] and [Remove] have the shapes of the container/heap functions
+// of the same names, but operate on a [heap.Heap].
+package compat
+
+import (
+	stdheap "container/heap"
+	"iter"
+	"slices"
+
+	"github.com/jba/heap"
+)
+
+// A Wrapper provides the methods of a [heap.Heap] on top of
+// a [container/heap.Interface].
+type Wrapper[T any] struct {
+	h  stdheap.Interface
+	at func(int) T
+}
+
+var _ heap.IndexedQueue[int] = (*Wrapper[int])(nil)
+
+// Wrap returns a [Wrapper] for h.
+// The function at returns the element of h at index i.
+// Elements are passed to h.Push and returned from h.Pop as values of type T.
+//
+// The wrapper owns h: the caller must not use h subsequently, except
+// through at. If the contents of h do not already satisfy the heap property,
+// call [container/heap.Init] first.
+func Wrap[T any](h stdheap.Interface, at func(i int) T) *Wrapper[T] {
+	return &Wrapper[T]{h: h, at: at}
+}
+
+// Init adds the elements of s to the heap, which must be empty,
+// and establishes the heap property.
+// Init panics if the heap is not empty.
+func (w *Wrapper[T]) Init(s []T) {
+	if w.h.Len() != 0 {
+		panic("compat: Init: heap is not empty")
+	}
+	w.pushAll(slices.Values(s))
+}
+
+// Insert adds an element to the heap.
+func (w *Wrapper[T]) Insert(value T) {
+	stdheap.Push(w.h, value)
+}
+
+// InsertAll adds all elements of the sequence to the heap,
+// re-establishing the heap property at the end.
+func (w *Wrapper[T]) InsertAll(seq iter.Seq[T]) {
+	w.pushAll(seq)
+}
+
+func (w *Wrapper[T]) pushAll(seq iter.Seq[T]) {
+	for v := range seq {
+		w.h.Push(v)
+	}
+	stdheap.Init(w.h)
+}
+
+// Min returns the minimum element in the heap without removing it.
+// It panics if the heap is empty.
+func (w *Wrapper[T]) Min() T {
+	if w.h.Len() == 0 {
+		panic("compat: Min called on empty heap")
+	}
+	return w.at(0)
+}
+
+// TakeMin removes and returns the minimum element from the heap.
+// It panics if the heap is empty.
+func (w *Wrapper[T]) TakeMin() T {
+	if w.h.Len() == 0 {
+		panic("compat: TakeMin called on empty heap")
+	}
+	return stdheap.Pop(w.h).(T)
+}
+
+// ChangeMin replaces the minimum value in the heap with the given value.
+// It panics if the heap is empty.
+func (w *Wrapper[T]) ChangeMin(v T) {
+	n := w.h.Len()
+	if n == 0 {
+		panic("compat: ChangeMin called on empty heap")
+	}
+	// Put v at the end, swap it with the minimum, and discard the minimum.
+	w.h.Push(v)
+	w.h.Swap(0, n)
+	w.h.Pop()
+	stdheap.Fix(w.h, 0)
+}
+
+// Clear removes all elements from the heap.
+func (w *Wrapper[T]) Clear() {
+	for w.h.Len() > 0 {
+		w.h.Pop()
+	}
+}
+
+// Len returns the number of elements in the heap.
+func (w *Wrapper[T]) Len() int {
+	return w.h.Len()
+}
+
+// All returns an iterator over all elements in the heap
+// in unspecified order.
+func (w *Wrapper[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := range w.h.Len() {
+			if !yield(w.at(i)) {
+				return
+			}
+		}
+	}
+}
+
+// Drain removes and returns the heap elements in sorted order,
+// from smallest to largest.
+//
+// The result is undefined if the heap is changed during iteration.
+func (w *Wrapper[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for w.h.Len() > 0 {
+			if !yield(w.TakeMin()) {
+				return
+			}
+		}
+	}
+}
+
+// Delete removes the element at index i from the heap.
+// It panics if i is out of range.
+func (w *Wrapper[T]) Delete(i int) {
+	if i < 0 || i >= w.h.Len() {
+		panic("compat: Delete: index out of range")
+	}
+	stdheap.Remove(w.h, i)
+}
+
+// Changed restores the heap property after the element at index i has
+// been modified.
+// It panics if i is out of range.
+func (w *Wrapper[T]) Changed(i int) {
+	if i < 0 || i >= w.h.Len() {
+		panic("compat: Changed: index out of range")
+	}
+	stdheap.Fix(w.h, i)
+}
+
+// Init is like [container/heap.Init]: it establishes the heap property
+// for h. Since a [heap.Heap] always maintains the heap property,
+// calling Init is only necessary after elements of h have been modified
+// without calling [heap.Heap.Changed].
+func Init[T any](h *heap.Heap[T]) {
+	s := slices.Collect(h.All())
+	h.Clear()
+	h.Init(s)
+}
+
+// Push is like [container/heap.Push]: it adds x to h.
+func Push[T any](h *heap.Heap[T], x T) {
+	h.Insert(x)
+}
+
+// Pop is like [container/heap.Pop]: it removes and returns the minimum
+// element of h.
+func Pop[T any](h *heap.Heap[T]) T {
+	return h.TakeMin()
+}
+
+// Fix is like [container/heap.Fix]: it restores the heap property after
+// the element at index i has changed.
+func Fix[T any](h *heap.Heap[T], i int) {
+	h.Changed(i)
+}
+
+// Remove is like [container/heap.Remove]: it removes and returns the element
+// at index i. It takes time proportional to i to find the element;
+// to remove an element that is already at hand, use [heap.Heap.Delete].
+func Remove[T any](h *heap.Heap[T], i int) T {
+	if i < 0 || i >= h.Len() {
+		panic("compat: Remove: index out of range")
+	}
+	var x T
+	j := 0
+	for v := range h.All() {
+		if j == i {
+			x = v
+			break
+		}
+		j++
+	}
+	h.Delete(i)
+	return x
+}
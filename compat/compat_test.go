@@ -0,0 +1,221 @@
+package compat
+
+import (
+	stdheap "container/heap"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/jba/heap"
+	"github.com/jba/heap/heaptest"
+)
+
+// An item has a key, on which it is ordered, and an id that distinguishes
+// items with equal keys, so the tests detect any difference in how ties
+// are ordered.
+type item struct {
+	key, id int
+	index   int
+}
+
+func compareItems(a, b *item) int { return a.key - b.key }
+
+func setItemIndex(it *item, i int) { it.index = i }
+
+// items implements container/heap.Interface.
+type items []*item
+
+func (s items) Len() int           { return len(s) }
+func (s items) Less(i, j int) bool { return s[i].key < s[j].key }
+
+func (s items) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].index = i
+	s[j].index = j
+}
+
+func (s *items) Push(x any) {
+	it := x.(*item)
+	it.index = len(*s)
+	*s = append(*s, it)
+}
+
+func (s *items) Pop() any {
+	old := *s
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.index = -1
+	*s = old[:n-1]
+	return it
+}
+
+func ids(s []*item) []int {
+	var r []int
+	for _, it := range s {
+		r = append(r, it.id)
+	}
+	return r
+}
+
+// TestFunctions checks that the functions in this package arrange a heap.Heap
+// exactly as the container/heap functions arrange a slice.
+func TestFunctions(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	nextID := 0
+	newPair := func() (*item, *item) {
+		k := r.IntN(10) // small keys, for many ties
+		nextID++
+		return &item{key: k, id: nextID}, &item{key: k, id: nextID}
+	}
+
+	var (
+		s  items
+		h  = heap.NewIndexed(compareItems, setItemIndex)
+		hs []*item
+	)
+	for range 20 {
+		a, b := newPair()
+		s = append(s, a)
+		hs = append(hs, b)
+	}
+	for i, it := range s {
+		it.index = i
+	}
+	stdheap.Init(&s)
+	h.Init(hs)
+
+	for n := range 5000 {
+		var op string
+		switch k := r.IntN(6); {
+		case k <= 1 || len(s) == 0:
+			op = "Push"
+			a, b := newPair()
+			stdheap.Push(&s, a)
+			Push(h, b)
+		case k == 2:
+			op = "Pop"
+			if a, b := stdheap.Pop(&s).(*item), Pop(h); a.id != b.id {
+				t.Fatalf("#%d: Pop: got id %d, want %d", n, b.id, a.id)
+			}
+		case k == 3:
+			op = "Remove"
+			i := r.IntN(len(s))
+			if a, b := stdheap.Remove(&s, i).(*item), Remove(h, i); a.id != b.id || b.index != -1 {
+				t.Fatalf("#%d: Remove(%d): got id %d, index %d; want id %d, index -1", n, i, b.id, b.index, a.id)
+			}
+		case k == 4:
+			op = "Fix"
+			i := r.IntN(len(s))
+			key := r.IntN(10)
+			s[i].key = key
+			slices.Collect(h.All())[i].key = key
+			stdheap.Fix(&s, i)
+			Fix(h, i)
+		default:
+			op = "Init"
+			// Disturb the heap without telling it, then restore it.
+			i := r.IntN(len(s))
+			key := r.IntN(10)
+			s[i].key = key
+			slices.Collect(h.All())[i].key = key
+			stdheap.Init(&s)
+			Init(h)
+		}
+		got := slices.Collect(h.All())
+		if !slices.Equal(ids(got), ids(s)) {
+			t.Fatalf("#%d: after %s:\ngot  %v\nwant %v", n, op, ids(got), ids(s))
+		}
+		for i, it := range got {
+			if it.index != i {
+				t.Fatalf("#%d: after %s: item %d has index %d", n, op, i, it.index)
+			}
+		}
+	}
+}
+
+// TestWrapper checks that a Wrapper yields elements in the same order as
+// a heap.Heap, including ties.
+func TestWrapper(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	var s items
+	w := Wrap(&s, func(i int) *item { return s[i] })
+	h := heap.NewIndexed(compareItems, setItemIndex)
+	var ws, hs []*item
+	for id := range 100 {
+		k := r.IntN(10)
+		ws = append(ws, &item{key: k, id: id})
+		hs = append(hs, &item{key: k, id: id})
+	}
+	w.Init(ws[:50])
+	h.Init(hs[:50])
+	w.InsertAll(slices.Values(ws[50:]))
+	h.InsertAll(slices.Values(hs[50:]))
+	w.ChangeMin(&item{key: 5, id: 100})
+	h.ChangeMin(&item{key: 5, id: 100})
+	w.Delete(17)
+	h.Delete(17)
+	var got, want []int
+	for it := range w.Drain() {
+		got = append(got, it.id)
+	}
+	for it := range h.Drain() {
+		want = append(want, it.id)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+}
+
+// elems implements container/heap.Interface for heaptest.
+type elems struct {
+	s   []*heaptest.Elem
+	cmp func(a, b *heaptest.Elem) int
+	set func(*heaptest.Elem, int)
+}
+
+func (h *elems) Len() int           { return len(h.s) }
+func (h *elems) Less(i, j int) bool { return h.cmp(h.s[i], h.s[j]) < 0 }
+
+func (h *elems) Swap(i, j int) {
+	h.s[i], h.s[j] = h.s[j], h.s[i]
+	h.set(h.s[i], i)
+	h.set(h.s[j], j)
+}
+
+func (h *elems) Push(x any) {
+	e := x.(*heaptest.Elem)
+	h.set(e, len(h.s))
+	h.s = append(h.s, e)
+}
+
+func (h *elems) Pop() any {
+	n := len(h.s) - 1
+	e := h.s[n]
+	h.s[n] = nil
+	h.s = h.s[:n]
+	h.set(e, -1)
+	return e
+}
+
+func TestWrapperConformance(t *testing.T) {
+	heaptest.Run(t, func(compare func(a, b *heaptest.Elem) int, setIndex func(*heaptest.Elem, int)) heaptest.Heap[*heaptest.Elem] {
+		h := &elems{cmp: compare, set: setIndex}
+		return Wrap(h, func(i int) *heaptest.Elem { return h.s[i] })
+	})
+}
+
+func TestRemovePanics(t *testing.T) {
+	h := heap.New(func(a, b int) int { return a - b })
+	h.Insert(1)
+	for _, i := range []int{-1, 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Remove(%d) did not panic", i)
+				}
+			}()
+			Remove(h, i)
+		}()
+	}
+}
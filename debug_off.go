@@ -0,0 +1,7 @@
+//go:build !heapdebug
+
+package heap
+
+// debug enables verifying the heap after every operation (see Heap.Verify).
+// Build with the heapdebug tag to turn it on.
+const debug = false
@@ -0,0 +1,6 @@
+//go:build heapdebug
+
+package heap
+
+// debug enables verifying the heap after every operation (see Heap.Verify).
+const debug = true
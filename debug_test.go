@@ -0,0 +1,68 @@
+//go:build heapdebug
+
+package heap
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDebugPanics(t *testing.T) {
+	h := NewIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) { v.index = i })
+	for i := range 10 {
+		h.Insert(&intIndexed{value: i})
+	}
+	// Modify an element without calling Changed.
+	h.values[9].value = -1
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("Insert did not panic")
+		}
+		msg := fmt.Sprint(r)
+		if want := "heap: Insert: element at index 9"; !strings.HasPrefix(msg, want) {
+			t.Errorf("got panic %q, want prefix %q", msg, want)
+		}
+		if want := "its parent at index 4"; !strings.Contains(msg, want) {
+			t.Errorf("got panic %q, want it to contain %q", msg, want)
+		}
+	}()
+	h.Insert(&intIndexed{value: 100})
+}
+
+func TestDebugIndexPanics(t *testing.T) {
+	var broken *intIndexed
+	h := NewIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) {
+		if v != broken {
+			v.index = i
+		}
+	})
+	h.SetIndexOf(func(v *intIndexed) int { return v.index })
+	for i := range 10 {
+		h.Insert(&intIndexed{value: i})
+	}
+	// Make the index function drop the updates for one element.
+	broken = h.values[9]
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("Delete did not panic")
+		}
+		msg := fmt.Sprint(r)
+		if want := "heap: Delete: "; !strings.HasPrefix(msg, want) {
+			t.Errorf("got panic %q, want prefix %q", msg, want)
+		}
+		if want := "has recorded index 9"; !strings.Contains(msg, want) {
+			t.Errorf("got panic %q, want it to contain %q", msg, want)
+		}
+	}()
+	h.Delete(0)
+}
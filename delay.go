@@ -0,0 +1,222 @@
+package heap
+
+import (
+	"sync"
+	"time"
+)
+
+// A Clock tells the time and schedules functions to run in the future.
+// It lets a [DelayQueue] be tested without real delays.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// AfterFunc arranges for f to be called in its own goroutine after
+	// duration d, like [time.AfterFunc]. The returned function stops the
+	// call, and reports whether it did so before f was called.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// realClock is the [Clock] based on the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// A DelayQueue holds items until their deadlines, and then delivers them.
+// It uses a single timer for all its items, set for the earliest deadline.
+// A DelayQueue is safe for concurrent use by multiple goroutines.
+type DelayQueue[T any] struct {
+	mu        sync.Mutex
+	h         *Heap[*Delayed[T]]
+	clock     Clock
+	deliver   func(T)
+	timerStop func() bool // stops the current timer, or nil
+	timerAt   time.Time   // the time the current timer is set for
+	timerGen  uint64      // incremented for each new timer
+	ready     []T         // due items waiting to be delivered, in order
+	sending   bool        // whether a goroutine is delivering ready items
+}
+
+// A Delayed is a handle for an item scheduled in a [DelayQueue].
+// It can be used to cancel or reschedule the item until it is delivered.
+type Delayed[T any] struct {
+	value    T
+	deadline time.Time
+	index    int
+	q        *DelayQueue[T] // the queue the item was scheduled in
+}
+
+// Value returns the item.
+func (d *Delayed[T]) Value() T {
+	return d.value
+}
+
+// NewDelayQueue creates a new [DelayQueue] that calls deliver with each
+// item when its deadline arrives. The deliver function is called in a
+// separate goroutine, and should not block for long.
+// Items are delivered one at a time, in deadline order: while deliver
+// blocks, the items that come due wait for it to return.
+// If clock is nil, the queue uses the system clock.
+func NewDelayQueue[T any](clock Clock, deliver func(T)) *DelayQueue[T] {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &DelayQueue[T]{
+		h: NewIndexed(func(a, b *Delayed[T]) int {
+			return a.deadline.Compare(b.deadline)
+		}, func(d *Delayed[T], i int) { d.index = i }),
+		clock:   clock,
+		deliver: deliver,
+	}
+}
+
+// NewDelayQueueChan creates a new [DelayQueue] that delivers items on
+// the returned channel, which has the given buffer size.
+// When the buffer is full, delivery of later items waits until the
+// receiver catches up.
+// If clock is nil, the queue uses the system clock.
+func NewDelayQueueChan[T any](clock Clock, size int) (*DelayQueue[T], <-chan T) {
+	c := make(chan T, size)
+	return NewDelayQueue(clock, func(v T) { c <- v }), c
+}
+
+// Schedule adds value to the queue, to be delivered at deadline.
+// If the deadline has passed, the value is delivered promptly.
+// It returns a handle that can be passed to [DelayQueue.Cancel] and
+// [DelayQueue.Reschedule].
+func (q *DelayQueue[T]) Schedule(value T, deadline time.Time) *Delayed[T] {
+	d := &Delayed[T]{value: value, deadline: deadline, q: q}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.h.Insert(d)
+	q.arm()
+	return d
+}
+
+// Cancel removes the item with handle d from the queue.
+// It reports whether it did so; it returns false if the item is already
+// due, or has been canceled.
+// Cancel panics if d was not returned by q.Schedule.
+func (q *DelayQueue[T]) Cancel(d *Delayed[T]) bool {
+	q.checkHandle("Cancel", d)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if d.index < 0 {
+		return false
+	}
+	q.h.Delete(d.index)
+	q.arm()
+	return true
+}
+
+// Reschedule changes the deadline of the item with handle d.
+// It reports whether it did so; it returns false if the item is already
+// due, or has been canceled.
+// Reschedule panics if d was not returned by q.Schedule.
+func (q *DelayQueue[T]) Reschedule(d *Delayed[T], deadline time.Time) bool {
+	q.checkHandle("Reschedule", d)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if d.index < 0 {
+		return false
+	}
+	d.deadline = deadline
+	q.h.Changed(d.index)
+	q.arm()
+	return true
+}
+
+// checkHandle panics if d does not belong to q.
+func (q *DelayQueue[T]) checkHandle(method string, d *Delayed[T]) {
+	if d.q != q {
+		panic("heap: " + method + ": handle does not belong to this queue")
+	}
+}
+
+// Len returns the number of items in the queue that have not been
+// delivered, including items that are due but waiting for the delivery of
+// earlier items.
+func (q *DelayQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ready) + q.h.Len()
+}
+
+// Stop removes all items from the queue without delivering them,
+// and returns them in deadline order. Only an item whose delivery has
+// already begun is delivered after Stop returns.
+// The queue can continue to be used after Stop.
+func (q *DelayQueue[T]) Stop() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := q.ready
+	q.ready = nil
+	for d := range q.h.Drain() {
+		pending = append(pending, d.value)
+	}
+	q.arm()
+	return pending
+}
+
+// arm sets the timer for the earliest deadline, or stops it if the queue
+// is empty. It must be called with q.mu held.
+func (q *DelayQueue[T]) arm() {
+	if q.h.Len() == 0 {
+		if q.timerStop != nil {
+			q.timerStop()
+			q.timerStop = nil
+		}
+		return
+	}
+	next := q.h.Min().deadline
+	if q.timerStop != nil {
+		if q.timerAt.Equal(next) {
+			return
+		}
+		q.timerStop()
+	}
+	q.timerAt = next
+	q.timerGen++
+	gen := q.timerGen
+	q.timerStop = q.clock.AfterFunc(max(next.Sub(q.clock.Now()), 0), func() { q.fire(gen) })
+}
+
+// fire moves the items that are due to the ready list, and delivers them
+// unless another call to fire is already delivering.
+// The argument identifies the timer that called fire. A timer that was
+// stopped too late may call fire early, or more than once; that is
+// harmless, as long as it does not forget the current timer.
+func (q *DelayQueue[T]) fire(gen uint64) {
+	q.mu.Lock()
+	now := q.clock.Now()
+	for q.h.Len() > 0 && !q.h.Min().deadline.After(now) {
+		q.ready = append(q.ready, q.h.TakeMin().value)
+	}
+	if gen == q.timerGen {
+		// The current timer has fired, so there is none to stop.
+		q.timerStop = nil
+	}
+	q.arm()
+	if q.sending {
+		// The delivering goroutine will deliver the new items
+		// after the ones before them.
+		q.mu.Unlock()
+		return
+	}
+	q.sending = true
+	for len(q.ready) > 0 {
+		v := q.ready[0]
+		var zero T
+		q.ready[0] = zero // allow GC
+		q.ready = q.ready[1:]
+		q.mu.Unlock()
+		q.deliver(v)
+		q.mu.Lock()
+	}
+	q.ready = nil
+	q.sending = false
+	q.mu.Unlock()
+}
@@ -0,0 +1,322 @@
+package heap
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	when    time.Time
+	f       func()
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		wasActive := !t.stopped
+		t.stopped = true
+		return wasActive
+	}
+}
+
+// Advance moves the clock forward by d, calling the functions of timers
+// that expire, synchronously and in order.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if !t.stopped && !t.when.After(end) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		next.stopped = true
+		c.now = next.when
+		c.mu.Unlock()
+		next.f()
+	}
+}
+
+func (c *fakeClock) activeTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.timers {
+		if !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDelayQueue(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	var got []string
+	q := NewDelayQueue(clock, func(s string) { got = append(got, s) })
+	start := clock.Now()
+	at := func(secs int) time.Time { return start.Add(time.Duration(secs) * time.Second) }
+
+	q.Schedule("c", at(3))
+	q.Schedule("a", at(1))
+	b := q.Schedule("b", at(2))
+	d := q.Schedule("d", at(4))
+	if q.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", q.Len())
+	}
+	if n := clock.activeTimers(); n != 1 {
+		t.Errorf("%d active timers, want 1", n)
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	if want := []string{"a"}; !slices.Equal(got, want) {
+		t.Errorf("after 1.5s: got %v, want %v", got, want)
+	}
+
+	// Move b after d, and cancel c.
+	if !q.Reschedule(b, at(5)) {
+		t.Error("Reschedule returned false")
+	}
+	clock.Advance(time.Second)
+	if want := []string{"a"}; !slices.Equal(got, want) {
+		t.Errorf("after 2.5s: got %v, want %v", got, want)
+	}
+	if !q.Cancel(q.Schedule("x", at(3))) {
+		t.Error("Cancel returned false")
+	}
+	clock.Advance(2 * time.Second)
+	if want := []string{"a", "c", "d"}; !slices.Equal(got, want) {
+		t.Errorf("after 4.5s: got %v, want %v", got, want)
+	}
+	if q.Cancel(d) || q.Reschedule(d, at(10)) {
+		t.Error("Cancel or Reschedule of a delivered item returned true")
+	}
+
+	clock.Advance(time.Second)
+	if want := []string{"a", "c", "d", "b"}; !slices.Equal(got, want) {
+		t.Errorf("after 5.5s: got %v, want %v", got, want)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", q.Len())
+	}
+	if n := clock.activeTimers(); n != 0 {
+		t.Errorf("%d active timers, want 0", n)
+	}
+}
+
+func TestDelayQueueSameDeadline(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	q, c := NewDelayQueueChan[int](clock, 10)
+	for i := range 5 {
+		q.Schedule(i, clock.Now().Add(time.Duration(5-i)*time.Millisecond))
+	}
+	// Items that are already due are delivered in deadline order.
+	q.Schedule(-1, clock.Now().Add(-time.Second))
+	clock.Advance(time.Second)
+	var got []int
+	for range 6 {
+		got = append(got, <-c)
+	}
+	if want := []int{-1, 4, 3, 2, 1, 0}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDelayQueueStop(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	q := NewDelayQueue(clock, func(int) { t.Error("unexpected delivery") })
+	q.Schedule(2, clock.Now().Add(2*time.Second))
+	q.Schedule(1, clock.Now().Add(time.Second))
+	if got, want := q.Stop(), []int{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("Stop() = %v, want %v", got, want)
+	}
+	clock.Advance(time.Minute)
+	if n := clock.activeTimers(); n != 0 {
+		t.Errorf("%d active timers, want 0", n)
+	}
+}
+
+func TestDelayQueueRealClock(t *testing.T) {
+	q, c := NewDelayQueueChan[int](nil, 1)
+	q.Schedule(1, time.Now().Add(time.Millisecond))
+	select {
+	case v := <-c:
+		if v != 1 {
+			t.Errorf("got %d, want 1", v)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("item not delivered")
+	}
+}
+
+// TestDelayQueueSlowReceiver checks that items are delivered one at a
+// time and in deadline order, even when items come due while an earlier
+// one is still being delivered.
+func TestDelayQueueSlowReceiver(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	const n = 20
+	var (
+		got        []int
+		delivering atomic.Int32
+		entered    = make(chan struct{}, n)
+		release    = make(chan struct{})
+	)
+	q := NewDelayQueue(clock, func(v int) {
+		if delivering.Add(1) > 1 {
+			t.Errorf("item %d delivered while another delivery is in progress", v)
+			delivering.Add(-1)
+			return
+		}
+		got = append(got, v)
+		entered <- struct{}{}
+		<-release
+		delivering.Add(-1)
+	})
+	for i := range n {
+		q.Schedule(i, clock.Now().Add(time.Duration(i+1)*time.Millisecond))
+	}
+
+	// Deliver item 0, which blocks until release is closed.
+	done := make(chan struct{})
+	go func() {
+		clock.Advance(time.Millisecond)
+		close(done)
+	}()
+	<-entered
+	// The other items come due while item 0 is being delivered.
+	for range n - 1 {
+		clock.Advance(time.Millisecond)
+	}
+	if q.Len() != n-1 {
+		t.Errorf("Len() = %d, want %d", q.Len(), n-1)
+	}
+	close(release)
+	<-done
+
+	if len(got) != n || !slices.IsSorted(got) {
+		t.Errorf("got %v, want 0 through %d in order", got, n-1)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", q.Len())
+	}
+}
+
+func TestDelayQueueStopWhileDelivering(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	entered := make(chan int, 4)
+	release := make(chan struct{})
+	q := NewDelayQueue(clock, func(v int) {
+		entered <- v
+		<-release
+	})
+	for i := range 3 {
+		q.Schedule(i, clock.Now().Add(time.Duration(i+1)*time.Second))
+	}
+
+	// Deliver item 0, which blocks until release is closed.
+	done := make(chan struct{})
+	go func() {
+		clock.Advance(time.Second)
+		close(done)
+	}()
+	if v := <-entered; v != 0 {
+		t.Fatalf("delivering %d, want 0", v)
+	}
+	// Item 1 comes due, and waits for item 0 to be delivered.
+	clock.Advance(time.Second)
+	if q.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", q.Len())
+	}
+	// Item 0 is being delivered, so Stop cannot remove it.
+	if got, want := q.Stop(), []int{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("Stop() = %v, want %v", got, want)
+	}
+	close(release)
+	<-done
+	// Once the delivering call to fire has returned, nothing else can be
+	// delivered.
+	select {
+	case v := <-entered:
+		t.Errorf("%d delivered after Stop", v)
+	default:
+	}
+	if n := clock.activeTimers(); n != 0 {
+		t.Errorf("%d active timers, want 0", n)
+	}
+}
+
+// TestDelayQueueLateStop checks that a timer that fires after it was
+// stopped does not make the queue lose track of the current timer.
+func TestDelayQueueLateStop(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	var got []string
+	q := NewDelayQueue(clock, func(s string) { got = append(got, s) })
+	q.Schedule("b", clock.Now().Add(2*time.Second))
+	stale := clock.timers[0]
+	// An earlier item replaces the timer.
+	q.Schedule("a", clock.Now().Add(time.Second))
+	if n := clock.activeTimers(); n != 1 {
+		t.Fatalf("%d active timers, want 1", n)
+	}
+	// The stopped timer fires anyway.
+	stale.f()
+	if n := clock.activeTimers(); n != 1 {
+		t.Errorf("after a late fire, %d active timers, want 1", n)
+	}
+	clock.Advance(3 * time.Second)
+	if want := []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := clock.activeTimers(); n != 0 {
+		t.Errorf("%d active timers, want 0", n)
+	}
+}
+
+func TestDelayQueueForeignHandle(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	q1 := NewDelayQueue(clock, func(int) {})
+	q2 := NewDelayQueue(clock, func(int) {})
+	q1.Schedule(1, clock.Now().Add(time.Second))
+	d := q2.Schedule(2, clock.Now().Add(time.Second))
+	if !panics(func() { q1.Cancel(d) }) {
+		t.Error("Cancel of another queue's handle should panic")
+	}
+	if !panics(func() { q1.Reschedule(d, clock.Now()) }) {
+		t.Error("Reschedule of another queue's handle should panic")
+	}
+	if !panics(func() { q1.Cancel(&Delayed[int]{}) }) {
+		t.Error("Cancel of a zero handle should panic")
+	}
+	if q1.Len() != 1 || q2.Len() != 1 {
+		t.Errorf("Len() = %d, %d; want 1, 1", q1.Len(), q2.Len())
+	}
+}
@@ -0,0 +1,507 @@
+// Package durable provides a priority queue that survives crashes.
+//
+// A [Queue] keeps its elements in a [heap.Heap] in memory, and records each
+// change in an append-only write-ahead log in a directory before making it.
+// From time to time it compacts the log by writing a snapshot of the heap
+// and starting a new, empty log. When a Queue is opened, it loads the latest
+// snapshot and replays the log on top of it.
+//
+// A crash can leave a partially written record at the end of the log. When
+// the queue is opened, the record is discarded and the log is truncated, so
+// the queue has the contents it had after the last complete change.
+package durable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/jba/heap"
+)
+
+// Options configure a [Queue]. The zero value is valid.
+type Options[T any] struct {
+	// SetIndex is the index function of the queue's heap.
+	// See [heap.NewIndexed]. Without one, [Queue.Delete] and
+	// [Queue.Changed] accept only index 0.
+	SetIndex func(T, int)
+
+	// CompactAfter is the number of log records after which the queue
+	// compacts the log. The default is 1000. If CompactAfter is negative,
+	// the queue compacts the log only when [Queue.Compact] is called.
+	CompactAfter int
+
+	// NoSync disables flushing the log to stable storage after each
+	// change. A crash of the program cannot lose changes, but a crash of
+	// the operating system can.
+	NoSync bool
+}
+
+// A Queue is a durable priority queue.
+//
+// The methods of a Queue behave like those of [heap.Heap], but the methods
+// that change the queue first record the change on disk. If they return an
+// error, the queue is unchanged in memory, and all subsequent changes return
+// the same error. Open the queue again to continue.
+//
+// A change may be followed by a compaction of the log (see
+// [Options.CompactAfter]). The change is complete by then, so if the
+// compaction fails, the change still succeeds, and the error is returned by
+// the next method that changes the queue, and by Close.
+//
+// A Queue is not safe for concurrent use, and only one Queue may use a
+// directory at a time.
+type Queue[T any] struct {
+	dir     string
+	h       *heap.Heap[T]
+	codec   heap.Codec[T]
+	opts    Options[T]
+	log     *os.File
+	gen     uint64 // generation of the snapshot and the log
+	records int    // number of records in the log
+	err     error  // sticky error
+}
+
+const (
+	snapshotFile = "snapshot"
+	logFile      = "log"
+
+	snapshotMagic = "HEAPSNAP"
+	logMagic      = "HEAPLOG1"
+	headerLen     = 8 + 8 // magic and generation
+
+	recordHeaderLen = 4 + 4 // payload length and checksum
+)
+
+// Log operations.
+const (
+	opInsert byte = iota + 1
+	opTakeMin
+	opDelete
+	opChanged
+)
+
+// ErrClosed is returned by the methods of a closed [Queue].
+var ErrClosed = errors.New("durable: queue is closed")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Open opens the queue stored in dir, creating the directory and an empty
+// queue if necessary. The queue orders elements with compare, and stores
+// them with codec. A queue must be opened with the same comparison function
+// and codec each time. The codec must not be nil.
+func Open[T any](dir string, compare func(T, T) int, codec heap.Codec[T], opts *Options[T]) (*Queue[T], error) {
+	if codec == nil {
+		return nil, errors.New("durable: Open: nil codec")
+	}
+	q := &Queue[T]{dir: dir, codec: codec}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.CompactAfter == 0 {
+		q.opts.CompactAfter = 1000
+	}
+	q.h = heap.NewIndexed(compare, q.opts.SetIndex)
+	q.h.SetCodec(codec)
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	// Remove the remains of an interrupted compaction.
+	for _, name := range []string{snapshotFile, logFile} {
+		if err := os.Remove(q.path(name + ".tmp")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err := q.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := q.replayLog(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue[T]) path(name string) string {
+	return filepath.Join(q.dir, name)
+}
+
+// loadSnapshot loads the snapshot, if there is one.
+func (q *Queue[T]) loadSnapshot() error {
+	data, err := os.ReadFile(q.path(snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	gen, ok := parseHeader(data, snapshotMagic)
+	if !ok {
+		return fmt.Errorf("durable: %s: invalid snapshot", q.path(snapshotFile))
+	}
+	if err := q.h.UnmarshalBinary(data[headerLen:]); err != nil {
+		return fmt.Errorf("durable: %s: %w", q.path(snapshotFile), err)
+	}
+	q.gen = gen
+	return nil
+}
+
+// replayLog applies the records of the log to the heap, truncates any
+// incomplete record at the end, and opens the log for appending.
+func (q *Queue[T]) replayLog() error {
+	name := q.path(logFile)
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return q.newLog()
+	}
+	if err != nil {
+		return err
+	}
+	gen, ok := parseHeader(data, logMagic)
+	switch {
+	case !ok:
+		return fmt.Errorf("durable: %s: invalid log", name)
+	case gen < q.gen:
+		// A crash occurred during compaction, after the snapshot was
+		// written. The snapshot includes all the changes in the log.
+		return q.newLog()
+	case gen > q.gen:
+		return fmt.Errorf("durable: %s: log is newer than snapshot", name)
+	}
+	off := headerLen
+	for {
+		payload, n := nextRecord(data[off:])
+		if n == 0 {
+			break
+		}
+		if err := q.apply(payload); err != nil {
+			return fmt.Errorf("durable: %s: record at offset %d: %w", name, off, err)
+		}
+		off += n
+		q.records++
+	}
+	if off < len(data) {
+		// Discard a torn record.
+		if err := truncate(name, int64(off)); err != nil {
+			return err
+		}
+	}
+	q.log, err = os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	return err
+}
+
+// nextRecord returns the payload of the record at the start of data and
+// the length of the record. It returns a length of 0 if data does not begin
+// with a complete record.
+func nextRecord(data []byte) ([]byte, int) {
+	if len(data) < recordHeaderLen {
+		return nil, 0
+	}
+	size := binary.BigEndian.Uint32(data)
+	if uint64(size) > uint64(len(data)-recordHeaderLen) {
+		return nil, 0
+	}
+	payload := data[recordHeaderLen : recordHeaderLen+int(size)]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(data[4:]) {
+		return nil, 0
+	}
+	return payload, recordHeaderLen + int(size)
+}
+
+// apply applies the change described by a log record.
+func (q *Queue[T]) apply(payload []byte) error {
+	errInvalid := errors.New("invalid record")
+	if len(payload) == 0 {
+		return errInvalid
+	}
+	op, rest := payload[0], payload[1:]
+	var i int
+	if op == opDelete || op == opChanged {
+		u, n := binary.Uvarint(rest)
+		if n <= 0 || u >= uint64(q.h.Len()) || (u != 0 && q.opts.SetIndex == nil) {
+			return errInvalid
+		}
+		i, rest = int(u), rest[n:]
+	}
+	var v T
+	if op == opInsert || op == opChanged {
+		var err error
+		if v, err = q.codec.Decode(rest); err != nil {
+			return err
+		}
+	} else if len(rest) != 0 {
+		return errInvalid
+	}
+	switch op {
+	case opInsert:
+		q.h.Insert(v)
+	case opTakeMin:
+		if q.h.Len() == 0 {
+			return errInvalid
+		}
+		q.h.TakeMin()
+	case opDelete:
+		q.h.Delete(i)
+	case opChanged:
+		q.change(i, v)
+	default:
+		return errInvalid
+	}
+	return nil
+}
+
+// write appends a record to the log.
+func (q *Queue[T]) write(op byte, i int, v *T) error {
+	if q.err != nil {
+		return q.err
+	}
+	rec := make([]byte, recordHeaderLen, 64)
+	rec = append(rec, op)
+	if op == opDelete || op == opChanged {
+		rec = binary.AppendUvarint(rec, uint64(i))
+	}
+	if v != nil {
+		e, err := q.codec.Encode(*v)
+		if err != nil {
+			return err
+		}
+		rec = append(rec, e...)
+	}
+	payload := rec[recordHeaderLen:]
+	binary.BigEndian.PutUint32(rec, uint32(len(payload)))
+	binary.BigEndian.PutUint32(rec[4:], crc32.Checksum(payload, crcTable))
+	if _, err := q.log.Write(rec); err != nil {
+		q.err = err
+		return err
+	}
+	if !q.opts.NoSync {
+		if err := q.log.Sync(); err != nil {
+			q.err = err
+			return err
+		}
+	}
+	q.records++
+	return nil
+}
+
+// maybeCompact compacts the log if it has enough records. It is called
+// after a change has been logged and made, so it does not report an error
+// to the caller; instead it makes the error sticky.
+func (q *Queue[T]) maybeCompact() {
+	if q.opts.CompactAfter > 0 && q.records >= q.opts.CompactAfter {
+		if err := q.Compact(); err != nil {
+			q.err = err
+		}
+	}
+}
+
+// checkIndex panics under the same conditions as [heap.Heap.Delete] and
+// [heap.Heap.Changed], before anything is written to the log.
+func (q *Queue[T]) checkIndex(method string, i int) {
+	if i < 0 || i >= q.h.Len() {
+		panic("durable: " + method + ": index out of range")
+	}
+	if i != 0 && q.opts.SetIndex == nil {
+		panic("durable: " + method + " called with non-zero index and no index function")
+	}
+}
+
+// Insert adds an element to the queue.
+func (q *Queue[T]) Insert(v T) error {
+	if err := q.write(opInsert, 0, &v); err != nil {
+		return err
+	}
+	q.h.Insert(v)
+	q.maybeCompact()
+	return nil
+}
+
+// Min returns the minimum element in the queue without removing it.
+// It panics if the queue is empty.
+func (q *Queue[T]) Min() T {
+	return q.h.Min()
+}
+
+// TakeMin removes and returns the minimum element from the queue.
+// It panics if the queue is empty.
+func (q *Queue[T]) TakeMin() (T, error) {
+	if q.h.Len() == 0 {
+		panic("durable: TakeMin called on empty queue")
+	}
+	if err := q.write(opTakeMin, 0, nil); err != nil {
+		var zero T
+		return zero, err
+	}
+	v := q.h.TakeMin()
+	q.maybeCompact()
+	return v, nil
+}
+
+// Delete removes the element at index i from the queue.
+// See [heap.Heap.Delete] for the reasonable values of i.
+func (q *Queue[T]) Delete(i int) error {
+	q.checkIndex("Delete", i)
+	if err := q.write(opDelete, i, nil); err != nil {
+		return err
+	}
+	q.h.Delete(i)
+	q.maybeCompact()
+	return nil
+}
+
+// Changed replaces the element at index i with v. To record a change to an
+// element that the queue holds by pointer, pass the element itself as v.
+// The index function, if any, is called with the old element and -1, and
+// then with v and its new index.
+// The change is logged as a single record, so after a crash the queue holds
+// either the old element or v, never both or neither.
+// See [heap.Heap.Changed] for the reasonable values of i.
+func (q *Queue[T]) Changed(i int, v T) error {
+	q.checkIndex("Changed", i)
+	if err := q.write(opChanged, i, &v); err != nil {
+		return err
+	}
+	q.change(i, v)
+	q.maybeCompact()
+	return nil
+}
+
+// change replaces the element at index i with v. The log records only the
+// new value, so the old element is removed and v inserted, rather than
+// changed in place.
+func (q *Queue[T]) change(i int, v T) {
+	q.h.Delete(i)
+	q.h.Insert(v)
+}
+
+// Len returns the number of elements in the queue.
+func (q *Queue[T]) Len() int {
+	return q.h.Len()
+}
+
+// All returns an iterator over all elements in the queue
+// in unspecified order.
+func (q *Queue[T]) All() iter.Seq[T] {
+	return q.h.All()
+}
+
+// Compact writes a snapshot of the queue and starts a new, empty log.
+func (q *Queue[T]) Compact() error {
+	if q.err != nil {
+		return q.err
+	}
+	data, err := q.h.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	snap := appendHeader(nil, snapshotMagic, q.gen+1)
+	if err := writeFileAtomic(q.path(snapshotFile), append(snap, data...)); err != nil {
+		q.err = err
+		return err
+	}
+	// The snapshot now supersedes the log. If we crash before replacing
+	// the log, the next Open will discard it.
+	q.gen++
+	q.log.Close()
+	if err := q.newLog(); err != nil {
+		q.err = err
+		return err
+	}
+	return nil
+}
+
+// newLog replaces the log with an empty one for the current generation,
+// and opens it for appending.
+func (q *Queue[T]) newLog() error {
+	name := q.path(logFile)
+	if err := writeFileAtomic(name, appendHeader(nil, logMagic, q.gen)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	q.log = f
+	q.records = 0
+	return nil
+}
+
+// Close closes the queue's log. It returns the sticky error, if there is one.
+func (q *Queue[T]) Close() error {
+	if q.err == ErrClosed {
+		return ErrClosed
+	}
+	err := q.err
+	q.err = ErrClosed
+	if cerr := q.log.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func appendHeader(b []byte, magic string, gen uint64) []byte {
+	b = append(b, magic...)
+	return binary.BigEndian.AppendUint64(b, gen)
+}
+
+func parseHeader(data []byte, magic string) (gen uint64, ok bool) {
+	if len(data) < headerLen || !bytes.Equal(data[:len(magic)], []byte(magic)) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data[len(magic):]), true
+}
+
+// writeFileAtomic replaces the contents of the named file with data, so
+// that after a crash the file has either its old contents or the new ones.
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// truncate truncates the named file to size and flushes it to stable storage.
+func truncate(name string, size int64) error {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(size)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir flushes the directory entries of dir to stable storage.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,503 @@
+package durable
+
+import (
+	"cmp"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// intCodec encodes an int in decimal.
+type intCodec struct{}
+
+func (intCodec) Encode(v int) ([]byte, error) { return strconv.AppendInt(nil, int64(v), 10), nil }
+
+func (intCodec) Decode(data []byte) (int, error) { return strconv.Atoi(string(data)) }
+
+func openInts(t *testing.T, dir string, opts *Options[int]) *Queue[int] {
+	t.Helper()
+	q, err := Open(dir, cmp.Compare[int], intCodec{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// randomOp applies a random change to q.
+func randomOp(t *testing.T, q *Queue[int], r *rand.Rand) {
+	t.Helper()
+	var err error
+	switch n := r.IntN(10); {
+	case q.Len() == 0 || n < 5:
+		err = q.Insert(r.IntN(100))
+	case n < 7:
+		_, err = q.TakeMin()
+	case n < 8:
+		err = q.Delete(0)
+	default:
+		err = q.Changed(0, r.IntN(100))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	r := rand.New(rand.NewPCG(1, 2))
+	q := openInts(t, dir, &Options[int]{CompactAfter: 7})
+	for range 100 {
+		randomOp(t, q, r)
+	}
+	want := slices.Collect(q.All())
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q = openInts(t, dir, nil)
+	defer q.Close()
+	// Recovery restores the layout of the heap, not just its elements.
+	if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+		t.Fatalf("after reopening:\ngot  %v\nwant %v", got, want)
+	}
+	for _, w := range slices.Sorted(slices.Values(want)) {
+		if got := must(q.TakeMin()); got != w {
+			t.Fatalf("TakeMin = %d, want %d", got, w)
+		}
+	}
+}
+
+// TestCrash simulates a crash at every offset of the log, by truncating it,
+// and checks that the queue recovers the state it had after the last complete
+// record.
+func TestCrash(t *testing.T) {
+	dir := t.TempDir()
+	r := rand.New(rand.NewPCG(3, 4))
+	q := openInts(t, dir, &Options[int]{CompactAfter: -1})
+	// Start from a snapshot, so recovery combines it with the log.
+	for range 10 {
+		randomOp(t, q, r)
+	}
+	if err := q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	type state struct {
+		size   int64 // log size after the change
+		values []int
+	}
+	states := []state{{logSize(t, dir), slices.Collect(q.All())}}
+	for range 30 {
+		randomOp(t, q, r)
+		states = append(states, state{logSize(t, dir), slices.Collect(q.All())})
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := readFile(t, filepath.Join(dir, snapshotFile))
+	log := readFile(t, filepath.Join(dir, logFile))
+
+	for off := int64(headerLen); off <= int64(len(log)); off++ {
+		crashDir := t.TempDir()
+		writeFile(t, filepath.Join(crashDir, snapshotFile), snapshot)
+		writeFile(t, filepath.Join(crashDir, logFile), log[:off])
+
+		var want []int
+		for _, s := range states {
+			if s.size <= off {
+				want = s.values
+			}
+		}
+		q := openInts(t, crashDir, nil)
+		if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+			t.Fatalf("offset %d:\ngot  %v\nwant %v", off, got, want)
+		}
+		// The torn record must be gone, so later records are not lost.
+		if err := q.Insert(1000); err != nil {
+			t.Fatal(err)
+		}
+		want = slices.Collect(q.All())
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+		q = openInts(t, crashDir, nil)
+		if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+			t.Fatalf("offset %d, after insert:\ngot  %v\nwant %v", off, got, want)
+		}
+		q.Close()
+	}
+}
+
+func TestCrashDuringChanged(t *testing.T) {
+	dir := t.TempDir()
+	q := openInts(t, dir, &Options[int]{CompactAfter: -1})
+	for _, v := range []int{1, 2, 3} {
+		if err := q.Insert(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	before := slices.Collect(q.All())
+	if err := q.Changed(0, 4); err != nil {
+		t.Fatal(err)
+	}
+	after := slices.Collect(q.All())
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := readFile(t, filepath.Join(dir, snapshotFile))
+	log := readFile(t, filepath.Join(dir, logFile))
+
+	for off := headerLen; off <= len(log); off++ {
+		crashDir := t.TempDir()
+		writeFile(t, filepath.Join(crashDir, snapshotFile), snapshot)
+		writeFile(t, filepath.Join(crashDir, logFile), log[:off])
+		want := before
+		if off == len(log) {
+			want = after
+		}
+		q := openInts(t, crashDir, nil)
+		if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+			t.Fatalf("offset %d:\ngot  %v\nwant %v", off, got, want)
+		}
+		q.Close()
+	}
+}
+
+func TestCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	q := openInts(t, dir, nil)
+	for _, v := range []int{5, 3, 8} {
+		if err := q.Insert(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := slices.Collect(q.All())
+	if err := q.Insert(1); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	// Damage the element of the last record, so its checksum fails.
+	name := filepath.Join(dir, logFile)
+	log := readFile(t, name)
+	log[len(log)-1] ^= 0xff
+	writeFile(t, name, log)
+
+	q = openInts(t, dir, nil)
+	defer q.Close()
+	if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := logSize(t, dir), int64(len(log)-recordHeaderLen-2); got != want {
+		t.Errorf("log size = %d, want %d", got, want)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	dir := t.TempDir()
+	q := openInts(t, dir, &Options[int]{CompactAfter: 5})
+	for i := range 23 {
+		if err := q.Insert(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.gen != 4 || q.records != 3 {
+		t.Errorf("gen = %d, records = %d; want 4, 3", q.gen, q.records)
+	}
+	want := slices.Collect(q.All())
+	q.Close()
+
+	q = openInts(t, dir, nil)
+	defer q.Close()
+	if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if q.gen != 4 || q.records != 3 {
+		t.Errorf("after reopening, gen = %d, records = %d; want 4, 3", q.gen, q.records)
+	}
+}
+
+// TestCrashDuringCompaction simulates crashes at each step of compaction.
+func TestCrashDuringCompaction(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		crash func(t *testing.T, dir string, old map[string][]byte)
+	}{
+		{
+			// The new snapshot was being written.
+			name: "snapshot",
+			crash: func(t *testing.T, dir string, old map[string][]byte) {
+				writeFile(t, filepath.Join(dir, snapshotFile+".tmp"), readFile(t, filepath.Join(dir, snapshotFile))[:10])
+				writeFile(t, filepath.Join(dir, snapshotFile), old[snapshotFile])
+				writeFile(t, filepath.Join(dir, logFile), old[logFile])
+			},
+		},
+		{
+			// The snapshot was written, but the log was not replaced.
+			name: "log",
+			crash: func(t *testing.T, dir string, old map[string][]byte) {
+				writeFile(t, filepath.Join(dir, logFile), old[logFile])
+			},
+		},
+		{
+			// The new log was being written.
+			name: "log tmp",
+			crash: func(t *testing.T, dir string, old map[string][]byte) {
+				writeFile(t, filepath.Join(dir, logFile+".tmp"), nil)
+				writeFile(t, filepath.Join(dir, logFile), old[logFile])
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q := openInts(t, dir, &Options[int]{CompactAfter: -1})
+			for _, v := range []int{4, 9, 2} {
+				if err := q.Insert(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := q.Compact(); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []int{7, 1} {
+				if err := q.Insert(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := q.TakeMin(); err != nil {
+				t.Fatal(err)
+			}
+			old := map[string][]byte{
+				snapshotFile: readFile(t, filepath.Join(dir, snapshotFile)),
+				logFile:      readFile(t, filepath.Join(dir, logFile)),
+			}
+			if err := q.Compact(); err != nil {
+				t.Fatal(err)
+			}
+			want := slices.Collect(q.All())
+			q.Close()
+
+			test.crash(t, dir, old)
+			q = openInts(t, dir, nil)
+			defer q.Close()
+			if got := slices.Collect(q.All()); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if err := q.Insert(0); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{snapshotFile, logFile} {
+				if _, err := os.Stat(filepath.Join(dir, name+".tmp")); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s.tmp: got %v, want not exist", name, err)
+				}
+			}
+		})
+	}
+}
+
+// item is an element of a queue with an index function.
+type item struct {
+	value int
+	index int
+}
+
+type itemCodec struct{}
+
+func (itemCodec) Encode(v *item) ([]byte, error) {
+	return strconv.AppendInt(nil, int64(v.value), 10), nil
+}
+
+func (itemCodec) Decode(data []byte) (*item, error) {
+	n, err := strconv.Atoi(string(data))
+	return &item{value: n}, err
+}
+
+func TestIndexed(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *Queue[*item] {
+		q, err := Open(dir,
+			func(a, b *item) int { return cmp.Compare(a.value, b.value) },
+			itemCodec{},
+			&Options[*item]{SetIndex: func(v *item, i int) { v.index = i }, CompactAfter: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	values := func(q *Queue[*item]) []int {
+		var vs []int
+		for v := range q.All() {
+			vs = append(vs, v.value)
+		}
+		return vs
+	}
+
+	q := open()
+	items := map[int]*item{}
+	for _, v := range []int{50, 20, 80, 10, 60, 30} {
+		items[v] = &item{value: v}
+		if err := q.Insert(items[v]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items[80].value = 5
+	if err := q.Changed(items[80].index, items[80]); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Delete(items[60].index); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Changed(items[50].index, &item{value: 70}); err != nil {
+		t.Fatal(err)
+	}
+	if items[50].index != -1 {
+		t.Errorf("replaced element has index %d, want -1", items[50].index)
+	}
+	want := values(q)
+	q.Close()
+
+	q = open()
+	defer q.Close()
+	if got := values(q); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err := q.h.VerifyIndexed(func(v *item) int { return v.index }); err != nil {
+		t.Error(err)
+	}
+	if got := q.Min().value; got != 5 {
+		t.Errorf("Min = %d, want 5", got)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	q := openInts(t, t.TempDir(), nil)
+	if err := q.Insert(1); err != nil {
+		t.Fatal(err)
+	}
+	// Make writes to the log fail.
+	q.log.Close()
+	err := q.Insert(2)
+	if err == nil {
+		t.Fatal("Insert succeeded, want error")
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len = %d after failed Insert, want 1", q.Len())
+	}
+	if _, err2 := q.TakeMin(); err2 != err {
+		t.Errorf("TakeMin: got %v, want sticky error %v", err2, err)
+	}
+	if err := q.Compact(); err == nil {
+		t.Error("Compact succeeded, want error")
+	}
+}
+
+// TestCompactionError checks that a change that triggers a failed compaction
+// still succeeds, and that the error is reported afterwards.
+func TestCompactionError(t *testing.T) {
+	dir := t.TempDir()
+	q := openInts(t, dir, &Options[int]{CompactAfter: 3})
+	// Make writing the snapshot fail.
+	if err := os.Mkdir(filepath.Join(dir, snapshotFile+".tmp"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []int{5, 2} {
+		if err := q.Insert(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The third change compacts the log.
+	v, err := q.TakeMin()
+	if err != nil {
+		t.Fatalf("TakeMin: %v", err)
+	}
+	if v != 2 || q.Len() != 1 {
+		t.Errorf("TakeMin = %d, Len = %d; want 2, 1", v, q.Len())
+	}
+	if err := q.Insert(7); err == nil {
+		t.Error("Insert after failed compaction succeeded, want error")
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len = %d after failed Insert, want 1", q.Len())
+	}
+	if err := q.Close(); err == nil || err == ErrClosed {
+		t.Errorf("Close: got %v, want the compaction error", err)
+	}
+
+	// The log still holds every change that succeeded.
+	q = openInts(t, dir, nil)
+	defer q.Close()
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{5}) {
+		t.Errorf("after reopening, got %v, want [5]", got)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	q := openInts(t, t.TempDir(), nil)
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Insert(1); err != ErrClosed {
+		t.Errorf("Insert: got %v, want ErrClosed", err)
+	}
+	if err := q.Close(); err != ErrClosed {
+		t.Errorf("Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestNewerLog(t *testing.T) {
+	dir := t.TempDir()
+	q := openInts(t, dir, nil)
+	q.Close()
+	writeFile(t, filepath.Join(dir, logFile), appendHeader(nil, logMagic, 1))
+	if _, err := Open(dir, cmp.Compare[int], intCodec{}, nil); err == nil {
+		t.Error("Open succeeded with a log newer than the snapshot")
+	}
+}
+
+func TestNilCodec(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "q")
+	if _, err := Open[int](dir, cmp.Compare[int], nil, nil); err == nil {
+		t.Fatal("Open succeeded with a nil codec")
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Open with a nil codec created %s: %v", dir, err)
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func logSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	fi, err := os.Stat(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Size()
+}
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
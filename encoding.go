@@ -0,0 +1,219 @@
+package heap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// A Codec converts the elements of a heap to and from bytes.
+// Set one with [Heap.SetCodec].
+type Codec[T any] interface {
+	// Encode returns the encoding of v.
+	Encode(v T) ([]byte, error)
+	// Decode returns the element encoded in data.
+	Decode(data []byte) (T, error)
+}
+
+// ErrChecksum is returned when decoding a heap whose data does not match
+// its checksum.
+var ErrChecksum = errors.New("heap: checksum mismatch")
+
+var (
+	_ encoding.BinaryMarshaler   = (*Heap[int])(nil)
+	_ encoding.BinaryUnmarshaler = (*Heap[int])(nil)
+	_ json.Marshaler             = (*Heap[int])(nil)
+	_ json.Unmarshaler           = (*Heap[int])(nil)
+)
+
+const (
+	encodingMagic   = "HEAP"
+	encodingVersion = 1
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SetCodec sets the codec that [Heap.MarshalBinary] and
+// [Heap.UnmarshalBinary] use for the elements of h.
+// Without one, they encode elements with [encoding/json].
+// [Heap.MarshalJSON] and [Heap.UnmarshalJSON] do not use the codec.
+func (h *Heap[T]) SetCodec(c Codec[T]) {
+	h.codec = c
+}
+
+func (h *Heap[T]) encode(v T) ([]byte, error) {
+	if h.codec != nil {
+		return h.codec.Encode(v)
+	}
+	return json.Marshal(v)
+}
+
+func (h *Heap[T]) decode(data []byte) (T, error) {
+	if h.codec != nil {
+		return h.codec.Decode(data)
+	}
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// MarshalBinary implements [encoding.BinaryMarshaler].
+//
+// The encoding consists of a header with a version number, the number of
+// elements, each element as encoded by the heap's codec (see
+// [Heap.SetCodec]) and preceded by its length, and a CRC-32C checksum of
+// everything before it.
+func (h *Heap[T]) MarshalBinary() ([]byte, error) {
+	b := append([]byte(encodingMagic), encodingVersion)
+	b = binary.AppendUvarint(b, uint64(len(h.values)))
+	for _, v := range h.values {
+		e, err := h.encode(v)
+		if err != nil {
+			return nil, fmt.Errorf("heap: MarshalBinary: %w", err)
+		}
+		b = binary.AppendUvarint(b, uint64(len(e)))
+		b = append(b, e...)
+	}
+	return binary.BigEndian.AppendUint32(b, crc32.Checksum(b, crcTable)), nil
+}
+
+// UnmarshalBinary implements [encoding.BinaryUnmarshaler].
+// It replaces the contents of h with the elements encoded in data, which
+// must have been produced by [Heap.MarshalBinary].
+//
+// The heap must have been created by one of the constructors in this
+// package, since the encoding does not include the comparison or index
+// functions. The elements are added as if by [Heap.Init], so the index
+// function is called for each of them.
+// If data is invalid, UnmarshalBinary returns an error and leaves h
+// unchanged.
+func (h *Heap[T]) UnmarshalBinary(data []byte) error {
+	if err := h.checkDecode(); err != nil {
+		return err
+	}
+	errInvalid := errors.New("heap: UnmarshalBinary: invalid data")
+	if len(data) < len(encodingMagic)+1+4 || string(data[:len(encodingMagic)]) != encodingMagic {
+		return errInvalid
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return ErrChecksum
+	}
+	if v := body[len(encodingMagic)]; v != encodingVersion {
+		return fmt.Errorf("heap: UnmarshalBinary: unsupported version %d", v)
+	}
+	body = body[len(encodingMagic)+1:]
+	n, k := binary.Uvarint(body)
+	if k <= 0 || n > uint64(len(body)) {
+		return errInvalid
+	}
+	body = body[k:]
+	s := make([]T, 0, n)
+	for range n {
+		size, k := binary.Uvarint(body)
+		if k <= 0 || size > uint64(len(body)-k) {
+			return errInvalid
+		}
+		v, err := h.decode(body[k : k+int(size)])
+		if err != nil {
+			return fmt.Errorf("heap: UnmarshalBinary: %w", err)
+		}
+		s = append(s, v)
+		body = body[k+int(size):]
+	}
+	if len(body) != 0 {
+		return errInvalid
+	}
+	h.replace(s)
+	return nil
+}
+
+// GobEncode implements [encoding/gob.GobEncoder]. It is equivalent to
+// [Heap.MarshalBinary].
+func (h *Heap[T]) GobEncode() ([]byte, error) {
+	return h.MarshalBinary()
+}
+
+// GobDecode implements [encoding/gob.GobDecoder]. It is equivalent to
+// [Heap.UnmarshalBinary].
+func (h *Heap[T]) GobDecode(data []byte) error {
+	return h.UnmarshalBinary(data)
+}
+
+// jsonHeap is the JSON encoding of a heap.
+// The checksum is the CRC-32C of the compacted Elements.
+type jsonHeap struct {
+	Version  int             `json:"version"`
+	Elements json.RawMessage `json:"elements"`
+	Checksum uint32          `json:"checksum"`
+}
+
+// MarshalJSON implements [encoding/json.Marshaler].
+//
+// The encoding is an object with the version of the encoding, the elements
+// of the heap as a JSON array, and a CRC-32C checksum of the array.
+// Elements are encoded with [encoding/json], not with the heap's codec.
+func (h *Heap[T]) MarshalJSON() ([]byte, error) {
+	values := h.values
+	if values == nil {
+		values = []T{}
+	}
+	elems, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("heap: MarshalJSON: %w", err)
+	}
+	return json.Marshal(jsonHeap{
+		Version:  encodingVersion,
+		Elements: elems,
+		Checksum: crc32.Checksum(elems, crcTable),
+	})
+}
+
+// UnmarshalJSON implements [encoding/json.Unmarshaler].
+// It replaces the contents of h with the elements encoded in data, which
+// must have been produced by [Heap.MarshalJSON].
+// The requirements and behavior are the same as for [Heap.UnmarshalBinary],
+// except that elements are decoded with [encoding/json], not with the heap's
+// codec.
+func (h *Heap[T]) UnmarshalJSON(data []byte) error {
+	if err := h.checkDecode(); err != nil {
+		return err
+	}
+	var jh jsonHeap
+	if err := json.Unmarshal(data, &jh); err != nil {
+		return fmt.Errorf("heap: UnmarshalJSON: %w", err)
+	}
+	if jh.Version != encodingVersion {
+		return fmt.Errorf("heap: UnmarshalJSON: unsupported version %d", jh.Version)
+	}
+	var elems bytes.Buffer
+	if err := json.Compact(&elems, jh.Elements); err != nil {
+		return fmt.Errorf("heap: UnmarshalJSON: %w", err)
+	}
+	if crc32.Checksum(elems.Bytes(), crcTable) != jh.Checksum {
+		return ErrChecksum
+	}
+	var s []T
+	if err := json.Unmarshal(elems.Bytes(), &s); err != nil {
+		return fmt.Errorf("heap: UnmarshalJSON: %w", err)
+	}
+	h.replace(s)
+	return nil
+}
+
+func (h *Heap[T]) checkDecode() error {
+	if h.compare == nil {
+		return errors.New("heap: cannot decode into a heap without a comparison function")
+	}
+	return nil
+}
+
+// replace replaces the contents of h with s.
+func (h *Heap[T]) replace(s []T) {
+	h.Clear()
+	h.Init(s)
+}
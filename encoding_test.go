@@ -0,0 +1,237 @@
+package heap
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	h := New(cmp.Compare[int])
+	h.Init([]int{5, 3, 8, 1, 9, 2, 7})
+	want := slices.Collect(h.All())
+
+	check := func(t *testing.T, got *Heap[int]) {
+		t.Helper()
+		if !slices.Equal(slices.Collect(got.All()), want) {
+			t.Errorf("got %v, want %v", slices.Collect(got.All()), want)
+		}
+		if err := got.Verify(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		data, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := New(cmp.Compare[int])
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		check(t, got)
+	})
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := New(cmp.Compare[int])
+		if err := json.Unmarshal(data, got); err != nil {
+			t.Fatal(err)
+		}
+		check(t, got)
+
+		// The checksum does not depend on formatting.
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		got = New(cmp.Compare[int])
+		if err := json.Unmarshal(indented.Bytes(), got); err != nil {
+			t.Fatal(err)
+		}
+		check(t, got)
+	})
+	t.Run("gob", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(h); err != nil {
+			t.Fatal(err)
+		}
+		got := New(cmp.Compare[int])
+		if err := gob.NewDecoder(&buf).Decode(got); err != nil {
+			t.Fatal(err)
+		}
+		check(t, got)
+	})
+	t.Run("empty", func(t *testing.T) {
+		for _, marshal := range []func(*Heap[int]) ([]byte, error){
+			(*Heap[int]).MarshalBinary,
+			(*Heap[int]).MarshalJSON,
+		} {
+			data, err := marshal(New(cmp.Compare[int]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := New(cmp.Compare[int])
+			got.Insert(1)
+			var uerr error
+			if data[0] == '{' {
+				uerr = got.UnmarshalJSON(data)
+			} else {
+				uerr = got.UnmarshalBinary(data)
+			}
+			if uerr != nil {
+				t.Fatal(uerr)
+			}
+			if got.Len() != 0 {
+				t.Errorf("got %d elements, want 0", got.Len())
+			}
+		}
+	})
+}
+
+// itemCodec encodes an intIndexed as its value in decimal.
+type itemCodec struct{}
+
+func (itemCodec) Encode(v *intIndexed) ([]byte, error) {
+	return strconv.AppendInt(nil, int64(v.value), 10), nil
+}
+
+func (itemCodec) Decode(data []byte) (*intIndexed, error) {
+	n, err := strconv.Atoi(string(data))
+	return &intIndexed{value: n}, err
+}
+
+func TestUnmarshalIndexed(t *testing.T) {
+	compare := func(a, b *intIndexed) int { return cmp.Compare(a.value, b.value) }
+	setIndex := func(v *intIndexed, i int) { v.index = i }
+	h := NewIndexed(compare, setIndex)
+	h.SetCodec(itemCodec{})
+	for _, v := range []int{4, 1, 3, 2} {
+		h.Insert(&intIndexed{value: v})
+	}
+	data, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The heap being replaced loses its elements.
+	got := NewIndexed(compare, setIndex)
+	got.SetCodec(itemCodec{})
+	old := &intIndexed{value: 0}
+	got.Insert(old)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if old.index != -1 {
+		t.Errorf("replaced element has index %d, want -1", old.index)
+	}
+	if err := got.VerifyIndexed(func(e *intIndexed) int { return e.index }); err != nil {
+		t.Fatal(err)
+	}
+	var values []int
+	for e := range got.Drain() {
+		values = append(values, e.value)
+	}
+	if want := []int{1, 2, 3, 4}; !slices.Equal(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+}
+
+func TestJSONIgnoresCodec(t *testing.T) {
+	h := New(cmp.Compare[int])
+	h.SetCodec(failCodec{bad: 2})
+	h.Init([]int{3, 1, 2})
+	if _, err := h.MarshalBinary(); !errors.Is(err, errBadValue) {
+		t.Fatalf("MarshalBinary: got %v, want the codec's error", err)
+	}
+	data, err := h.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jh jsonHeap
+	if err := json.Unmarshal(data, &jh); err != nil {
+		t.Fatal(err)
+	}
+	var elems []int
+	if err := json.Unmarshal(jh.Elements, &elems); err != nil {
+		t.Fatalf("elements are not a JSON array of ints: %v", err)
+	}
+	got := New(cmp.Compare[int])
+	got.SetCodec(failCodec{bad: 2})
+	if err := got.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := slices.Sorted(got.All()), []int{1, 2, 3}; !slices.Equal(g, w) {
+		t.Errorf("got %v, want %v", g, w)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	h := New(cmp.Compare[int])
+	h.Init([]int{3, 1, 2})
+	data, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jdata, err := h.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := slices.Clone(data)
+	corrupt[len(encodingMagic)+2] ^= 1
+
+	version := slices.Clone(data[:len(data)-4])
+	version[len(encodingMagic)] = 99
+	version = binary.BigEndian.AppendUint32(version, crc32.Checksum(version, crcTable))
+
+	jcorrupt := bytes.Replace(jdata, []byte("[1,"), []byte("[0,"), 1)
+
+	for _, test := range []struct {
+		name    string
+		json    bool
+		data    []byte
+		wantErr error
+	}{
+		{"checksum", false, corrupt, ErrChecksum},
+		{"truncated", false, data[:len(data)-1], ErrChecksum},
+		{"short", false, data[:3], nil},
+		{"version", false, version, nil},
+		{"json checksum", true, jcorrupt, ErrChecksum},
+		{"json version", true, []byte(`{"version":2,"elements":[],"checksum":0}`), nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := New(cmp.Compare[int])
+			got.Insert(10)
+			if test.json {
+				err = got.UnmarshalJSON(test.data)
+			} else {
+				err = got.UnmarshalBinary(test.data)
+			}
+			if err == nil {
+				t.Fatal("got nil, want error")
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("got %v, want %v", err, test.wantErr)
+			}
+			if got.Len() != 1 || got.Min() != 10 {
+				t.Errorf("heap was changed: %v", slices.Collect(got.All()))
+			}
+		})
+	}
+
+	var zero Heap[int]
+	if err := zero.UnmarshalBinary(data); err == nil {
+		t.Error("unmarshaling into a heap without a comparison function: got nil, want error")
+	}
+}
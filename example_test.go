@@ -3,6 +3,7 @@ package heap_test
 import (
 	"cmp"
 	"fmt"
+	"math"
 	"slices"
 
 	"github.com/jba/heap"
@@ -160,6 +161,16 @@ func Example_topK() {
 	// 10
 }
 
+func ExampleBounded() {
+	// A Bounded keeps the K largest elements, like Example_topK.
+	b := heap.NewBounded(cmp.Compare[int], 3)
+	b.InsertAll(slices.Values([]int{7, 2, 9, 1, 5, 8, 3, 6, 4, 10}))
+	fmt.Println(b.Sorted())
+
+	// Output:
+	// [8 9 10]
+}
+
 func ExampleHeap_InsertAll() {
 	h := heap.New(cmp.Compare[int])
 	h.Init([]int{5, 3, 1})
@@ -197,3 +208,100 @@ func Example_heapsort() {
 	// 7
 	// 9
 }
+
+func ExampleMinMaxHeap() {
+	h := heap.NewMinMax(cmp.Compare[int])
+	h.Init([]int{5, 3, 7, 1, 9})
+
+	// Take elements from both ends.
+	fmt.Println(h.TakeMin(), h.TakeMax())
+
+	// Drain the rest from largest to smallest.
+	for v := range h.DrainMax() {
+		fmt.Println(v)
+	}
+
+	// Output:
+	// 1 9
+	// 7
+	// 5
+	// 3
+}
+
+func ExampleMerge() {
+	a := []int{1, 4, 7}
+	b := []int{2, 5, 8}
+	c := []int{3, 6, 9}
+	for v := range heap.Merge(cmp.Compare[int], slices.Values(a), slices.Values(b), slices.Values(c)) {
+		fmt.Print(v, " ")
+	}
+	fmt.Println()
+
+	// Output:
+	// 1 2 3 4 5 6 7 8 9
+}
+
+func ExamplePriorityQueue() {
+	// Highest priority first.
+	pq := heap.NewPriorityQueue[string, string](func(a, b int) int { return cmp.Compare(b, a) })
+	pq.Set("banana", "yellow", 3)
+	pq.Set("apple", "red", 2)
+	pq.Set("pear", "green", 4)
+
+	// Change a priority by key.
+	pq.Set("apple", "red", 5)
+
+	for pq.Len() > 0 {
+		k, v, p := pq.PopMin()
+		fmt.Printf("%d:%s:%s ", p, k, v)
+	}
+	fmt.Println()
+
+	// Output:
+	// 5:apple:red 4:pear:green 3:banana:yellow
+}
+
+func ExampleIndexedPQ() {
+	// Dijkstra's shortest-path algorithm on a small graph.
+	type edge struct{ to, weight int }
+	graph := [][]edge{
+		0: {{1, 4}, {2, 1}},
+		1: {{3, 1}},
+		2: {{1, 2}, {3, 5}},
+		3: {},
+	}
+	dist := []int{0, math.MaxInt, math.MaxInt, math.MaxInt}
+
+	pq := heap.NewIndexedPQ(len(graph), cmp.Compare[int])
+	pq.Insert(0, 0)
+	for pq.Len() > 0 {
+		u, d := pq.TakeMin()
+		for _, e := range graph[u] {
+			if nd := d + e.weight; nd < dist[e.to] {
+				dist[e.to] = nd
+				if pq.Contains(e.to) {
+					pq.DecreaseKey(e.to, nd)
+				} else {
+					pq.Insert(e.to, nd)
+				}
+			}
+		}
+	}
+	fmt.Println(dist)
+
+	// Output:
+	// [0 3 1 4]
+}
+
+func ExampleHeap_Sorted() {
+	h := heap.New(cmp.Compare[int])
+	h.Init([]int{5, 3, 7, 1})
+
+	// Iterate in order without removing elements.
+	fmt.Println(slices.Collect(h.Sorted()))
+	fmt.Println(h.Smallest(2), h.Len())
+
+	// Output:
+	// [1 3 5 7]
+	// [1 3] 4
+}
@@ -0,0 +1,364 @@
+package heap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"os"
+)
+
+// An ExternalHeap is a heap that can hold more elements than fit in memory.
+// It keeps up to a fixed number of elements in an in-memory [Heap]. When
+// that heap is full, it writes its elements in sorted order to a temporary
+// file, called a run, and starts over. Removing the minimum merges the runs
+// with the in-memory heap, reading each run incrementally.
+//
+// To limit the number of open files, runs are merged by level. A run
+// written from memory has level 0, and when there are enough runs at one
+// level, they are merged into a single run at the next level. So each
+// element is rewritten once per level, a number of times logarithmic in the
+// number of runs written from memory.
+//
+// An ExternalHeap yields elements in the same order as a [Heap] with the
+// same comparison function would: in sorted order, with elements that
+// compare equal in an unspecified order.
+//
+// The methods that may read or write files return an error. Once one of
+// them fails, the heap is unusable: the failed call and all later ones
+// return the same error. Call [ExternalHeap.Close] to remove the temporary
+// files when the heap is no longer needed.
+type ExternalHeap[T any] struct {
+	mem     *Heap[T]       // elements in memory
+	runs    *Heap[*run[T]] // runs that have elements left, ordered by head
+	levels  []int          // number of runs at each level
+	budget  int            // maximum number of elements in mem
+	fanIn   int            // number of runs at a level that are merged
+	tempDir string         // where to create dir
+	dir     string         // directory holding the runs, or "" if not yet created
+	n       int            // number of elements in runs
+	nextRun int            // sequence number of the next run
+	err     error          // sticky error
+}
+
+// A run is a file of encoded elements in sorted order. Each element is
+// preceded by its length as a uvarint. The head of the run, its smallest
+// element not yet removed, is kept in memory.
+type run[T any] struct {
+	head  T
+	seq   int // creation order, to break ties
+	level int // 0 for a run written from memory, or 1 + the level of the merged runs
+	index int // index in the heap of runs
+	f     *os.File
+	r     *bufio.Reader
+}
+
+// defaultFanIn is the number of runs at one level that an ExternalHeap
+// merges into a run at the next level. There are fewer than defaultFanIn
+// runs, and so open files, at each level.
+const defaultFanIn = 16
+
+var errExternalClosed = errors.New("heap: ExternalHeap is closed")
+
+// NewExternal creates a new [ExternalHeap] that keeps at most budget
+// elements in memory, and converts elements to and from bytes with codec.
+// If codec is nil, elements are encoded with [encoding/json].
+// See [New] for the meaning of the comparison function.
+// NewExternal panics if budget is not positive.
+func NewExternal[T any](compare func(T, T) int, codec Codec[T], budget int) *ExternalHeap[T] {
+	if budget <= 0 {
+		panic("heap: NewExternal: budget must be positive")
+	}
+	mem := New(compare)
+	mem.SetCodec(codec)
+	h := &ExternalHeap[T]{
+		mem:    mem,
+		budget: budget,
+		fanIn:  defaultFanIn,
+	}
+	h.runs = h.newRunHeap()
+	return h
+}
+
+// newRunHeap returns an empty heap of runs, ordered by head.
+func (h *ExternalHeap[T]) newRunHeap() *Heap[*run[T]] {
+	return NewIndexed(func(a, b *run[T]) int {
+		if c := h.mem.compare(a.head, b.head); c != 0 {
+			return c
+		}
+		return a.seq - b.seq
+	}, func(r *run[T], i int) { r.index = i })
+}
+
+// SetTempDir sets the directory in which h creates its temporary files.
+// If dir is "" (the default), h uses [os.TempDir].
+// SetTempDir has no effect after h has written its first run.
+func (h *ExternalHeap[T]) SetTempDir(dir string) {
+	h.tempDir = dir
+}
+
+// Insert adds an element to the heap. If the in-memory heap is full, Insert
+// first writes its elements to a new run.
+func (h *ExternalHeap[T]) Insert(value T) error {
+	if h.err != nil {
+		return h.err
+	}
+	if h.mem.Len() >= h.budget {
+		if err := h.spill(); err != nil {
+			h.err = err
+			return err
+		}
+	}
+	h.mem.Insert(value)
+	return nil
+}
+
+// InsertAll calls [ExternalHeap.Insert] on each element of the sequence,
+// stopping at the first error.
+func (h *ExternalHeap[T]) InsertAll(seq iter.Seq[T]) error {
+	for v := range seq {
+		if err := h.Insert(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of elements in the heap, in memory and on disk.
+func (h *ExternalHeap[T]) Len() int {
+	return h.mem.Len() + h.n
+}
+
+// Min returns the minimum element in the heap without removing it.
+// It panics if the heap is empty.
+func (h *ExternalHeap[T]) Min() T {
+	if h.fromMem("Min") {
+		return h.mem.Min()
+	}
+	return h.runs.Min().head
+}
+
+// TakeMin removes and returns the minimum element from the heap.
+// It panics if the heap is empty.
+func (h *ExternalHeap[T]) TakeMin() (T, error) {
+	if h.err != nil {
+		var zero T
+		return zero, h.err
+	}
+	if h.fromMem("TakeMin") {
+		return h.mem.TakeMin(), nil
+	}
+	r := h.runs.Min()
+	v := r.head
+	if err := h.advance(h.runs, r); err != nil {
+		h.err = err
+		var zero T
+		return zero, err
+	}
+	h.n--
+	return v, nil
+}
+
+// fromMem reports whether the minimum element is in memory.
+// It panics if the heap is empty.
+func (h *ExternalHeap[T]) fromMem(method string) bool {
+	switch {
+	case h.Len() == 0:
+		panic("heap: " + method + " called on empty heap")
+	case h.mem.Len() == 0:
+		return false
+	case h.runs.Len() == 0:
+		return true
+	default:
+		return h.mem.compare(h.mem.Min(), h.runs.Min().head) <= 0
+	}
+}
+
+// Drain removes and returns the heap elements in sorted order,
+// from smallest to largest. If an error occurs, the iteration stops
+// early; call [ExternalHeap.Err] to check for one afterwards.
+//
+// The result is undefined if the heap is changed during iteration.
+func (h *ExternalHeap[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for h.err == nil && h.Len() > 0 {
+			v, err := h.TakeMin()
+			if err != nil || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the error that made the heap unusable, if any.
+func (h *ExternalHeap[T]) Err() error {
+	if h.err == errExternalClosed {
+		return nil
+	}
+	return h.err
+}
+
+// Close removes the heap's temporary files. After Close, the heap is empty
+// and cannot be used.
+func (h *ExternalHeap[T]) Close() error {
+	if h.err == errExternalClosed {
+		return nil
+	}
+	var err error
+	for r := range h.runs.All() {
+		if cerr := r.f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if h.dir != "" {
+		if rerr := os.RemoveAll(h.dir); err == nil {
+			err = rerr
+		}
+	}
+	h.mem.Clear()
+	h.runs.Clear()
+	h.levels = nil
+	h.n = 0
+	h.err = errExternalClosed
+	return err
+}
+
+// spill writes the elements of the in-memory heap to a new run, and merges
+// runs if a level is full.
+func (h *ExternalHeap[T]) spill() error {
+	n := h.mem.Len()
+	if err := h.writeRun(h.mem.Drain(), 0); err != nil {
+		return err
+	}
+	h.n += n
+	for level := 0; level < len(h.levels) && h.levels[level] >= h.fanIn; level++ {
+		if err := h.mergeLevel(level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeLevel merges all the runs at level into one run at the next level.
+func (h *ExternalHeap[T]) mergeLevel(level int) (err error) {
+	batch := h.newRunHeap()
+	var rs []*run[T]
+	for r := range h.runs.All() {
+		if r.level == level {
+			rs = append(rs, r)
+		}
+	}
+	for _, r := range rs {
+		h.runs.Delete(r.index)
+		batch.Insert(r)
+	}
+	defer func() {
+		// Return the unmerged runs, so that Close closes them.
+		for r := range batch.Drain() {
+			h.runs.Insert(r)
+		}
+	}()
+	werr := h.writeRun(func(yield func(T) bool) {
+		for batch.Len() > 0 {
+			r := batch.Min()
+			if !yield(r.head) {
+				return
+			}
+			if err = h.advance(batch, r); err != nil {
+				return
+			}
+		}
+	}, level+1)
+	if err != nil {
+		return err
+	}
+	return werr
+}
+
+// writeRun writes the elements of seq, which must be sorted, to a new run
+// at the given level and adds the run to h.runs.
+func (h *ExternalHeap[T]) writeRun(seq iter.Seq[T], level int) (err error) {
+	if h.dir == "" {
+		if h.dir, err = os.MkdirTemp(h.tempDir, "heap-"); err != nil {
+			return err
+		}
+	}
+	f, err := os.CreateTemp(h.dir, "run-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	w := bufio.NewWriter(f)
+	var buf []byte
+	for v := range seq {
+		e, err := h.mem.encode(v)
+		if err != nil {
+			return err
+		}
+		buf = binary.AppendUvarint(buf[:0], uint64(len(e)))
+		w.Write(buf)
+		w.Write(e)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := &run[T]{seq: h.nextRun, level: level, f: f, r: bufio.NewReader(f)}
+	h.nextRun++
+	if r.head, err = h.read(r); err != nil {
+		if err == io.EOF {
+			err = errors.New("heap: ExternalHeap: empty run")
+		}
+		return err
+	}
+	h.runs.Insert(r)
+	for len(h.levels) <= level {
+		h.levels = append(h.levels, 0)
+	}
+	h.levels[level]++
+	return nil
+}
+
+// advance replaces the head of r, which must be the minimum run of runs,
+// with the next element of the run. If the run has no more elements,
+// advance removes it.
+func (h *ExternalHeap[T]) advance(runs *Heap[*run[T]], r *run[T]) error {
+	v, err := h.read(r)
+	if err == io.EOF {
+		runs.TakeMin()
+		h.levels[r.level]--
+		r.f.Close()
+		return os.Remove(r.f.Name())
+	}
+	if err != nil {
+		return err
+	}
+	r.head = v
+	runs.ChangeMin(r)
+	return nil
+}
+
+// read reads the next element of r. It returns io.EOF at the end of the run.
+func (h *ExternalHeap[T]) read(r *run[T]) (T, error) {
+	var zero T
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return zero, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return zero, err
+	}
+	return h.mem.decode(data)
+}
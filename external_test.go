@@ -0,0 +1,323 @@
+package heap
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// intCodec encodes an int in decimal.
+type intCodec struct{}
+
+func (intCodec) Encode(v int) ([]byte, error) { return strconv.AppendInt(nil, int64(v), 10), nil }
+
+func (intCodec) Decode(data []byte) (int, error) { return strconv.Atoi(string(data)) }
+
+func newExternalInts(t *testing.T, budget, fanIn int) *ExternalHeap[int] {
+	t.Helper()
+	h := NewExternal(cmp.Compare[int], intCodec{}, budget)
+	if fanIn > 0 {
+		h.fanIn = fanIn
+	}
+	h.SetTempDir(t.TempDir())
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestExternalDrain(t *testing.T) {
+	for _, test := range []struct {
+		budget, fanIn, n int
+	}{
+		{1, 0, 10},
+		{10, 0, 0},
+		{10, 0, 5},
+		{10, 0, 1000},
+		{7, 3, 1000}, // merges runs
+		{1000, 0, 1000},
+	} {
+		r := rand.New(rand.NewPCG(uint64(test.n), uint64(test.budget)))
+		h := newExternalInts(t, test.budget, test.fanIn)
+		want := New(cmp.Compare[int])
+		for range test.n {
+			v := r.IntN(test.n/2 + 1)
+			if err := h.Insert(v); err != nil {
+				t.Fatal(err)
+			}
+			want.Insert(v)
+		}
+		if h.Len() != test.n {
+			t.Errorf("%+v: Len = %d, want %d", test, h.Len(), test.n)
+		}
+		if test.fanIn > 0 && h.runs.Len() >= test.fanIn*len(h.levels) {
+			t.Errorf("%+v: %d runs in %d levels", test, h.runs.Len(), len(h.levels))
+		}
+		got := slices.Collect(h.Drain())
+		if err := h.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if w := slices.Collect(want.Drain()); !slices.Equal(got, w) {
+			t.Errorf("%+v:\ngot  %v\nwant %v", test, got, w)
+		}
+		if h.Len() != 0 {
+			t.Errorf("%+v: Len = %d after Drain, want 0", test, h.Len())
+		}
+	}
+}
+
+// TestExternalInterleaved checks that an ExternalHeap behaves like a Heap
+// under a random mix of operations.
+func TestExternalInterleaved(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	h := newExternalInts(t, 8, 4)
+	want := New(cmp.Compare[int])
+	for i := range 5000 {
+		if want.Len() == 0 || r.IntN(3) > 0 {
+			v := r.IntN(1000)
+			if err := h.Insert(v); err != nil {
+				t.Fatal(err)
+			}
+			want.Insert(v)
+			continue
+		}
+		if got, w := h.Min(), want.Min(); got != w {
+			t.Fatalf("op %d: Min = %d, want %d", i, got, w)
+		}
+		got, err := h.TakeMin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := want.TakeMin(); got != w {
+			t.Fatalf("op %d: TakeMin = %d, want %d", i, got, w)
+		}
+		if h.Len() != want.Len() {
+			t.Fatalf("op %d: Len = %d, want %d", i, h.Len(), want.Len())
+		}
+	}
+}
+
+// TestExternalLevels checks that runs are merged by level, so that each
+// element is rewritten once per level rather than at every merge.
+func TestExternalLevels(t *testing.T) {
+	for _, test := range []struct {
+		budget, fanIn, n int
+		want             []int // number of runs at each level
+	}{
+		{2, 4, 2*64 + 1, []int{0, 0, 0, 1}},
+		{2, 4, 2*70 + 1, []int{2, 1, 0, 1}},
+		// More spills than the old limit of 64 runs, with the default fan-in.
+		{1, 0, 1000, []int{7, 14, 3}},
+	} {
+		h := newExternalInts(t, test.budget, test.fanIn)
+		r := rand.New(rand.NewPCG(1, uint64(test.n)))
+		for range test.n {
+			if err := h.Insert(r.IntN(100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !slices.Equal(h.levels, test.want) {
+			t.Errorf("%+v: runs per level = %v, want %v", test, h.levels, test.want)
+		}
+		// A run at level L holds the elements of fanIn^L runs from memory,
+		// so each element has been written once per level.
+		total := 0
+		for r := range h.runs.All() {
+			size := runSize(t, r)
+			if want := test.budget * pow(h.fanIn, r.level); size != want {
+				t.Errorf("%+v: run at level %d has %d elements, want %d", test, r.level, size, want)
+			}
+			total += size
+		}
+		if total != h.n {
+			t.Errorf("%+v: runs hold %d elements, but h.n = %d", test, total, h.n)
+		}
+		if got := slices.Collect(h.Drain()); len(got) != test.n || !slices.IsSorted(got) {
+			t.Errorf("%+v: Drain returned %d elements, sorted: %t", test, len(got), slices.IsSorted(got))
+		}
+		if err := h.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(h.levels, make([]int, len(h.levels))) {
+			t.Errorf("%+v: after Drain, runs per level = %v", test, h.levels)
+		}
+	}
+}
+
+// runSize returns the number of elements written to r.
+func runSize(t *testing.T, r *run[int]) int {
+	t.Helper()
+	f, err := os.Open(r.f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	n := 0
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return n
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := br.Discard(int(size)); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+}
+
+func pow(b, e int) int {
+	n := 1
+	for range e {
+		n *= b
+	}
+	return n
+}
+
+func TestExternalTies(t *testing.T) {
+	type pair struct{ Key, ID int }
+	compare := func(a, b pair) int { return cmp.Compare(a.Key, b.Key) }
+	// With the default codec, elements are encoded with encoding/json.
+	h := NewExternal(compare, nil, 5)
+	h.SetTempDir(t.TempDir())
+	defer h.Close()
+	var want []pair
+	for i := range 100 {
+		p := pair{Key: i % 7, ID: i}
+		want = append(want, p)
+		if err := h.Insert(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := slices.Collect(h.Drain())
+	if err := h.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSortedFunc(got, compare) {
+		t.Errorf("not sorted: %v", got)
+	}
+	order := func(a, b pair) int { return cmp.Or(compare(a, b), cmp.Compare(a.ID, b.ID)) }
+	slices.SortFunc(got, order)
+	slices.SortFunc(want, order)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExternalTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	h := NewExternal(cmp.Compare[int], intCodec{}, 2)
+	h.SetTempDir(dir)
+	runFiles := func() int {
+		ents, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ents) == 0 {
+			return 0
+		}
+		runs, err := os.ReadDir(h.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(runs)
+	}
+
+	for i := range 7 {
+		if err := h.Insert(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := runFiles(); got != 3 {
+		t.Errorf("%d run files, want 3", got)
+	}
+	// A run's file is removed when the run is exhausted.
+	for range 3 {
+		if _, err := h.TakeMin(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := runFiles(); got != 2 {
+		t.Errorf("after TakeMin, %d run files, want 2", got)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ents, _ := os.ReadDir(dir); len(ents) != 0 {
+		t.Errorf("after Close, temp dir has %d entries", len(ents))
+	}
+	if h.Len() != 0 {
+		t.Errorf("after Close, Len = %d", h.Len())
+	}
+	if err := h.Insert(1); err == nil {
+		t.Error("Insert after Close succeeded")
+	}
+	if err := h.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+// failCodec is an intCodec that fails to encode its bad value.
+type failCodec struct{ bad int }
+
+var errBadValue = errors.New("bad value")
+
+func (c failCodec) Encode(v int) ([]byte, error) {
+	if v == c.bad {
+		return nil, errBadValue
+	}
+	return intCodec{}.Encode(v)
+}
+
+func (failCodec) Decode(data []byte) (int, error) { return intCodec{}.Decode(data) }
+
+func TestExternalError(t *testing.T) {
+	h := NewExternal(cmp.Compare[int], failCodec{bad: 3}, 4)
+	h.SetTempDir(t.TempDir())
+	defer h.Close()
+	for i := range 4 {
+		if err := h.Insert(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The heap is full, so Insert writes a run, which contains 3.
+	if err := h.Insert(4); !errors.Is(err, errBadValue) {
+		t.Fatalf("Insert: got %v, want %v", err, errBadValue)
+	}
+	if _, err := h.TakeMin(); !errors.Is(err, errBadValue) {
+		t.Errorf("TakeMin: got %v, want sticky %v", err, errBadValue)
+	}
+	if got := slices.Collect(h.Drain()); len(got) != 0 {
+		t.Errorf("Drain yielded %v after an error", got)
+	}
+	if err := h.Err(); !errors.Is(err, errBadValue) {
+		t.Errorf("Err: got %v, want %v", err, errBadValue)
+	}
+}
+
+func TestExternalPanics(t *testing.T) {
+	h := newExternalInts(t, 1, 0)
+	for name, f := range map[string]func(){
+		"Min":     func() { h.Min() },
+		"TakeMin": func() { h.TakeMin() },
+		"budget":  func() { NewExternal(cmp.Compare[int], nil, 0) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,166 @@
+package heap
+
+import "iter"
+
+// A Handle refers to an element of a [HandleHeap].
+// It remains valid as the element moves within the heap, until the element
+// is removed. The zero Handle is never valid.
+type Handle struct {
+	slot int
+	gen  uint64
+}
+
+// A HandleHeap is a min-heap that returns a [Handle] for each inserted
+// element, which can be used to refer to the element later.
+//
+// Unlike a [Heap] created with [NewIndexed], a HandleHeap places no
+// requirements on its elements: they need not be distinct, and they need
+// not have an index field, so value types such as int may be used.
+type HandleHeap[T any] struct {
+	h     *Heap[handleEntry[T]]
+	slots []handleSlot
+	free  []int // indexes of unused slots
+}
+
+// handleEntry is an element of a HandleHeap's underlying Heap.
+type handleEntry[T any] struct {
+	value T
+	slot  int
+}
+
+// A handleSlot tracks the position of an element in the heap.
+// Its generation changes each time the slot is reused, so that handles
+// to removed elements can be detected.
+type handleSlot struct {
+	index int // index in the heap, or -1 if unused
+	gen   uint64
+}
+
+// NewHandleHeap creates a new [HandleHeap] with the given comparison function.
+// See [New] for the meaning of the comparison function.
+func NewHandleHeap[T any](compare func(T, T) int) *HandleHeap[T] {
+	hh := &HandleHeap[T]{}
+	hh.h = NewIndexed(func(a, b handleEntry[T]) int {
+		return compare(a.value, b.value)
+	}, hh.setIndex)
+	return hh
+}
+
+func (hh *HandleHeap[T]) setIndex(e handleEntry[T], i int) {
+	s := &hh.slots[e.slot]
+	s.index = i
+	if i < 0 {
+		// The element was removed. Invalidate its handles.
+		s.gen++
+		hh.free = append(hh.free, e.slot)
+	}
+}
+
+// Insert adds an element to the heap and returns a handle to it.
+func (hh *HandleHeap[T]) Insert(value T) Handle {
+	var slot int
+	if n := len(hh.free); n > 0 {
+		slot = hh.free[n-1]
+		hh.free = hh.free[:n-1]
+	} else {
+		slot = len(hh.slots)
+		// Start at generation 1 so the zero Handle is never valid.
+		hh.slots = append(hh.slots, handleSlot{gen: 1})
+	}
+	hh.h.Insert(handleEntry[T]{value: value, slot: slot})
+	return Handle{slot: slot, gen: hh.slots[slot].gen}
+}
+
+// Contains reports whether h refers to an element of the heap.
+// It returns false if the element has been removed.
+func (hh *HandleHeap[T]) Contains(h Handle) bool {
+	return h.slot >= 0 && h.slot < len(hh.slots) && h.gen != 0 && hh.slots[h.slot].gen == h.gen
+}
+
+// index returns the heap index of the element referred to by h.
+// It panics if h is not valid.
+func (hh *HandleHeap[T]) index(h Handle, method string) int {
+	if !hh.Contains(h) {
+		panic("heap: " + method + ": invalid or stale handle")
+	}
+	return hh.slots[h.slot].index
+}
+
+// Value returns the element referred to by h.
+// It panics if h does not refer to an element of the heap.
+func (hh *HandleHeap[T]) Value(h Handle) T {
+	return hh.h.values[hh.index(h, "Value")].value
+}
+
+// Set replaces the element referred to by h with value, and restores
+// the heap property.
+// It panics if h does not refer to an element of the heap.
+func (hh *HandleHeap[T]) Set(h Handle, value T) {
+	i := hh.index(h, "Set")
+	hh.h.values[i].value = value
+	hh.h.Changed(i)
+}
+
+// Changed restores the heap property after the element referred to by h
+// has been modified.
+// It panics if h does not refer to an element of the heap.
+func (hh *HandleHeap[T]) Changed(h Handle) {
+	hh.h.Changed(hh.index(h, "Changed"))
+}
+
+// Delete removes the element referred to by h from the heap.
+// It panics if h does not refer to an element of the heap.
+func (hh *HandleHeap[T]) Delete(h Handle) {
+	hh.h.Delete(hh.index(h, "Delete"))
+}
+
+// Min returns the minimum element in the heap without removing it,
+// along with its handle.
+// It panics if the heap is empty.
+func (hh *HandleHeap[T]) Min() (T, Handle) {
+	e := hh.h.Min()
+	return e.value, Handle{slot: e.slot, gen: hh.slots[e.slot].gen}
+}
+
+// TakeMin removes and returns the minimum element from the heap.
+// It panics if the heap is empty.
+func (hh *HandleHeap[T]) TakeMin() T {
+	return hh.h.TakeMin().value
+}
+
+// Clear removes all elements from the heap.
+// All handles become invalid.
+func (hh *HandleHeap[T]) Clear() {
+	hh.h.Clear()
+}
+
+// Len returns the number of elements in the heap.
+func (hh *HandleHeap[T]) Len() int {
+	return hh.h.Len()
+}
+
+// All returns an iterator over all elements in the heap
+// in unspecified order.
+func (hh *HandleHeap[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for e := range hh.h.All() {
+			if !yield(e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Drain removes and returns the heap elements in sorted order,
+// from smallest to largest.
+//
+// The result is undefined if the heap is changed during iteration.
+func (hh *HandleHeap[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for e := range hh.h.Drain() {
+			if !yield(e.value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package heap
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestHandleHeap(t *testing.T) {
+	h := NewHandleHeap(cmp.Compare[int])
+	h5 := h.Insert(5)
+	h3 := h.Insert(3)
+	h7 := h.Insert(7)
+	h3b := h.Insert(3) // duplicates are allowed
+
+	if got := h.Value(h7); got != 7 {
+		t.Errorf("Value(h7) = %d, want 7", got)
+	}
+	if v, hm := h.Min(); v != 3 || (hm != h3 && hm != h3b) {
+		t.Errorf("Min() = %d, %v, want 3 and one of %v, %v", v, hm, h3, h3b)
+	}
+
+	h.Set(h5, 1)
+	h.Delete(h3)
+	if h.Contains(h3) {
+		t.Error("Contains(h3) after Delete")
+	}
+	if !h.Contains(h3b) {
+		t.Error("!Contains(h3b)")
+	}
+
+	// A new element may reuse h3's slot, but h3 stays invalid.
+	h9 := h.Insert(9)
+	if h.Contains(h3) {
+		t.Error("Contains(h3) after slot reuse")
+	}
+	if got := h.Value(h9); got != 9 {
+		t.Errorf("Value(h9) = %d, want 9", got)
+	}
+
+	if got, want := slices.Collect(h.Drain()), []int{1, 3, 7, 9}; !slices.Equal(got, want) {
+		t.Errorf("Drain: got %v, want %v", got, want)
+	}
+	for _, hd := range []Handle{h5, h3b, h7, h9} {
+		if h.Contains(hd) {
+			t.Errorf("Contains(%v) after Drain", hd)
+		}
+	}
+	if h.Contains(Handle{}) {
+		t.Error("Contains(Handle{})")
+	}
+}
+
+func TestHandleHeapRandom(t *testing.T) {
+	h := NewHandleHeap(cmp.Compare[int])
+	model := map[Handle]int{}
+	var handles []Handle // including stale ones
+
+	for range 3000 {
+		switch op := rand.IntN(10); {
+		case op < 4:
+			v := rand.IntN(100)
+			hd := h.Insert(v)
+			if _, ok := model[hd]; ok {
+				t.Fatalf("Insert returned live handle %v", hd)
+			}
+			model[hd] = v
+			handles = append(handles, hd)
+		case op < 5 && h.Len() > 0:
+			v, hd := h.Min()
+			if got := h.TakeMin(); got != v {
+				t.Fatalf("TakeMin() = %d, Min() = %d", got, v)
+			}
+			delete(model, hd)
+		case op < 8 && len(handles) > 0:
+			hd := handles[rand.IntN(len(handles))]
+			if _, ok := model[hd]; ok {
+				v := rand.IntN(100)
+				h.Set(hd, v)
+				model[hd] = v
+			} else if !panics(func() { h.Set(hd, 0) }) {
+				t.Fatalf("Set with stale handle %v did not panic", hd)
+			}
+		case len(handles) > 0:
+			hd := handles[rand.IntN(len(handles))]
+			if _, ok := model[hd]; ok {
+				h.Delete(hd)
+				delete(model, hd)
+			} else if !panics(func() { h.Delete(hd) }) {
+				t.Fatalf("Delete with stale handle %v did not panic", hd)
+			}
+		}
+
+		if h.Len() != len(model) {
+			t.Fatalf("Len() = %d, want %d", h.Len(), len(model))
+		}
+		for _, hd := range handles {
+			v, ok := model[hd]
+			if h.Contains(hd) != ok {
+				t.Fatalf("Contains(%v) = %t, want %t", hd, !ok, ok)
+			}
+			if ok && h.Value(hd) != v {
+				t.Fatalf("Value(%v) = %d, want %d", hd, h.Value(hd), v)
+			}
+		}
+	}
+
+	var want []int
+	for _, v := range model {
+		want = append(want, v)
+	}
+	slices.Sort(want)
+	if got := slices.Collect(h.Drain()); !slices.Equal(got, want) {
+		t.Errorf("Drain: got %v, want %v", got, want)
+	}
+}
+
+func TestHandleHeapChanged(t *testing.T) {
+	type task struct{ priority int }
+	h := NewHandleHeap(func(a, b *task) int { return cmp.Compare(a.priority, b.priority) })
+	a := &task{1}
+	b := &task{2}
+	ha := h.Insert(a)
+	h.Insert(b)
+	a.priority = 3
+	h.Changed(ha)
+	if v, _ := h.Min(); v != b {
+		t.Errorf("Min() = %v, want %v", v, b)
+	}
+	h.Clear()
+	if h.Contains(ha) {
+		t.Error("Contains after Clear")
+	}
+}
@@ -3,14 +3,22 @@ package heap
 
 import (
 	"iter"
+	"math/bits"
+	"reflect"
 	"slices"
 )
 
-// A Heap is a binary min-heap.
+// A Heap is a min-heap.
+// Heaps created with [New] or [NewIndexed] are binary heaps.
+// Use [NewDary] or [NewDaryIndexed] to choose a different arity.
 type Heap[T any] struct {
 	values   []T
 	compare  func(T, T) int
 	setIndex func(T, int)
+	codec    Codec[T]    // for encoding elements; see SetCodec
+	indexOf  func(T) int // for verifying indexes; see SetIndexOf
+	arity    int         // number of children per node
+	cow      bool        // values may be shared with a snapshot; copy before writing
 }
 
 // New creates a new [Heap] with the given comparison function.
@@ -19,7 +27,7 @@ type Heap[T any] struct {
 //   - zero if a == b
 //   - a positive value if a > b.
 func New[T any](compare func(T, T) int) *Heap[T] {
-	return &Heap[T]{compare: compare}
+	return &Heap[T]{compare: compare, arity: 2}
 }
 
 // NewIndexed creates a new [Heap] with the given comparison function and
@@ -32,7 +40,27 @@ func New[T any](compare func(T, T) int) *Heap[T] {
 // A Heap created with NewIndexed supports the [Heap.Delete] and [Heap.Changed]
 // methods.
 func NewIndexed[T any](compare func(T, T) int, setIndex func(T, int)) *Heap[T] {
-	return &Heap[T]{compare: compare, setIndex: setIndex}
+	return &Heap[T]{compare: compare, setIndex: setIndex, arity: 2}
+}
+
+// NewDary creates a new [Heap] with the given comparison function
+// in which each node has d children.
+// A wider heap is shallower, so insertions are faster and removals
+// are slower than in a binary heap.
+// NewDary panics if d is less than 2.
+func NewDary[T any](compare func(T, T) int, d int) *Heap[T] {
+	return NewDaryIndexed(compare, d, nil)
+}
+
+// NewDaryIndexed creates a new [Heap] with the given comparison function and
+// index function in which each node has d children.
+// See [NewIndexed] for the requirements on the index function.
+// NewDaryIndexed panics if d is less than 2.
+func NewDaryIndexed[T any](compare func(T, T) int, d int, setIndex func(T, int)) *Heap[T] {
+	if d < 2 {
+		panic("heap: arity must be at least 2")
+	}
+	return &Heap[T]{compare: compare, setIndex: setIndex, arity: d}
 }
 
 // Init creates a heap from the slice.
@@ -43,21 +71,29 @@ func (h *Heap[T]) Init(s []T) {
 		panic("heap: Init: heap is not empty")
 	}
 	h.values = s
+	h.cow = false
 	if h.setIndex != nil {
 		for i, e := range s {
 			h.setIndex(e, i)
 		}
 	}
 	h.heapify()
+	if debug {
+		h.check("Init")
+	}
 }
 
 // Insert adds an element to the heap.
 func (h *Heap[T]) Insert(value T) {
+	h.own()
 	h.values = append(h.values, value)
 	if h.setIndex != nil {
 		h.setIndex(value, len(h.values)-1)
 	}
 	h.up(len(h.values) - 1)
+	if debug {
+		h.check("Insert")
+	}
 }
 
 // InsertAll adds all elements of the sequence to the heap,
@@ -65,6 +101,7 @@ func (h *Heap[T]) Insert(value T) {
 // It is more efficient to call InsertAll on a long sequence than
 // it is to call [Heap.Insert] on each element of the sequence.
 func (h *Heap[T]) InsertAll(seq iter.Seq[T]) {
+	h.own()
 	start := len(h.values)
 	h.values = slices.AppendSeq(h.values, seq)
 	if h.setIndex != nil {
@@ -73,10 +110,60 @@ func (h *Heap[T]) InsertAll(seq iter.Seq[T]) {
 		}
 	}
 	h.heapify()
+	if debug {
+		h.check("InsertAll")
+	}
+}
+
+// Merge moves all the elements of other into h, leaving other empty.
+// If h has an index function, it is called for every moved element
+// with its new index in h. The heaps may have different arities;
+// h keeps its own.
+//
+// Merge panics if other is h, if the two heaps have different comparison
+// functions, or if only one of them has an index function.
+// The check of the comparison functions is only a best effort: functions
+// are considered the same if they have the same code. So two closures
+// created by the same function literal cannot be told apart, even if they
+// compare differently, and two different functions that compare the same
+// way are considered different.
+func (h *Heap[T]) Merge(other *Heap[T]) {
+	if other == h {
+		panic("heap: Merge: cannot merge a heap with itself")
+	}
+	if reflect.ValueOf(h.compare).Pointer() != reflect.ValueOf(other.compare).Pointer() {
+		panic("heap: Merge: heaps have different comparison functions")
+	}
+	if (h.setIndex == nil) != (other.setIndex == nil) {
+		panic("heap: Merge: only one heap has an index function")
+	}
+	h.own()
+	start := len(h.values)
+	h.values = append(h.values, other.values...)
+	if h.setIndex != nil {
+		for i, e := range h.values[start:] {
+			h.setIndex(e, start+i)
+		}
+	}
+	// Sifting up each new element costs O(m log n) for m new elements
+	// and n total, while re-heapifying costs O(n).
+	n := len(h.values)
+	if m := n - start; m*bits.Len(uint(n)) < n {
+		for i := start; i < n; i++ {
+			h.up(i)
+		}
+	} else {
+		h.heapify()
+	}
+	other.truncate()
+	if debug {
+		h.check("Merge")
+	}
 }
 
 func (h *Heap[T]) heapify() {
-	for i := len(h.values)/2 - 1; i >= 0; i-- {
+	// Start at the parent of the last element.
+	for i := (len(h.values) - 2) / h.arity; i >= 0; i-- {
 		h.down(i)
 	}
 }
@@ -98,6 +185,9 @@ func (h *Heap[T]) TakeMin() T {
 	}
 	min := h.values[0]
 	h.delete(0)
+	if debug {
+		h.check("TakeMin")
+	}
 	return min
 }
 
@@ -108,6 +198,18 @@ func (h *Heap[T]) Clear() {
 			h.setIndex(v, -1)
 		}
 	}
+	h.truncate()
+}
+
+// truncate removes all elements from the heap without calling
+// the index function.
+func (h *Heap[T]) truncate() {
+	if h.cow {
+		// Leave the shared values alone.
+		h.values = nil
+		h.cow = false
+		return
+	}
 	var zero T
 	for i := range h.values {
 		h.values[i] = zero // allow GC
@@ -146,6 +248,55 @@ func (h *Heap[T]) Drain() iter.Seq[T] {
 	}
 }
 
+// Sorted returns an iterator over the heap elements in sorted order,
+// from smallest to largest, without modifying the heap.
+// Yielding the first k elements takes O(k log k) time.
+//
+// The result is undefined if the heap is changed during iteration.
+func (h *Heap[T]) Sorted() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if len(h.values) == 0 {
+			return
+		}
+		// The frontier holds the indexes of elements whose parents have
+		// been yielded but which have not been yielded themselves.
+		// Its minimum is the smallest element not yet yielded.
+		frontier := New(func(i, j int) int { return h.compare(h.values[i], h.values[j]) })
+		frontier.Insert(0)
+		for frontier.Len() > 0 {
+			i := frontier.TakeMin()
+			if !yield(h.values[i]) {
+				return
+			}
+			fc := h.arity*i + 1
+			for c := fc; c < min(fc+h.arity, len(h.values)); c++ {
+				frontier.Insert(c)
+			}
+		}
+	}
+}
+
+// Smallest returns the k smallest elements of the heap in sorted order,
+// or all of them if there are fewer than k, without modifying the heap.
+// It takes O(k log k) time.
+// It panics if k is negative.
+func (h *Heap[T]) Smallest(k int) []T {
+	if k < 0 {
+		panic("heap: Smallest: negative k")
+	}
+	s := make([]T, 0, min(k, len(h.values)))
+	if k == 0 {
+		return s
+	}
+	for v := range h.Sorted() {
+		s = append(s, v)
+		if len(s) == k {
+			break
+		}
+	}
+	return s
+}
+
 // Delete removes the element at index i from the heap.
 // The only reasonable values for i are 0, for the minimum element (but
 // see [Heap.TakeMin]),
@@ -160,16 +311,22 @@ func (h *Heap[T]) Delete(i int) {
 		panic("heap: Delete called with non-zero index and no index function")
 	}
 	h.delete(i)
+	if debug {
+		h.check("Delete")
+	}
 }
 
 func (h *Heap[T]) delete(i int) {
-	if h.setIndex != nil {
-		h.setIndex(h.values[i], -1)
-	}
+	h.own()
 	n := len(h.values) - 1
 	if n != i {
 		h.swap(i, n)
 	}
+	// The swap set the index of the removed element, so mark it removed
+	// only now.
+	if h.setIndex != nil {
+		h.setIndex(h.values[n], -1)
+	}
 	var zero T
 	h.values[n] = zero // allow GC
 	h.values = h.values[:n]
@@ -190,24 +347,103 @@ func (h *Heap[T]) Changed(i int) {
 	if i != 0 && h.setIndex == nil {
 		panic("heap: Changed called with non-zero index and no index function")
 	}
+	h.own()
 	if !h.down(i) {
 		h.up(i)
 	}
+	if debug {
+		h.check("Changed")
+	}
 }
 
 // ChangeMin replaces the minimum value in the heap with the given value.
+// If the heap has an index function, it is called with the old minimum
+// and -1, and with v and its new index.
 // It panics if the heap is empty.
 func (h *Heap[T]) ChangeMin(v T) {
 	if len(h.values) == 0 {
 		panic("heap: ChangeMin called on empty heap")
 	}
+	h.own()
+	if h.setIndex != nil {
+		h.setIndex(h.values[0], -1)
+		h.setIndex(v, 0)
+	}
 	h.values[0] = v
 	h.down(0)
+	if debug {
+		h.check("ChangeMin")
+	}
+}
+
+// Clone returns a copy of h with the same elements, comparison function
+// and arity, but no index function. The index function of h is not called.
+// The elements themselves are not copied.
+//
+// Since the clone has no index function, it does not support calling
+// [Heap.Delete] or [Heap.Changed] with non-zero indexes. To clone an
+// indexed heap whose elements are shared with the clone, see
+// [Heap.CloneIndexed].
+func (h *Heap[T]) Clone() *Heap[T] {
+	return &Heap[T]{
+		values:  slices.Clone(h.values),
+		compare: h.compare,
+		codec:   h.codec,
+		arity:   h.arity,
+	}
+}
+
+// CloneIndexed returns a copy of h with the same elements, comparison
+// function and arity, and the given index function, which is called for
+// each element with its index in the copy. See [NewIndexed] for the
+// requirements on the index function.
+//
+// If the elements of h are pointers, they are shared by h and the clone,
+// and so setIndex must record indexes in a different place than the
+// index function of h.
+func (h *Heap[T]) CloneIndexed(setIndex func(T, int)) *Heap[T] {
+	c := h.Clone()
+	c.setIndex = setIndex
+	if setIndex != nil {
+		for i, e := range c.values {
+			setIndex(e, i)
+		}
+	}
+	return c
+}
+
+// Snapshot returns a copy of h that shares storage with h until either of
+// them is modified, at which point the modified heap makes its own copy.
+// Like the result of [Heap.Clone], the snapshot has no index function.
+// Taking a snapshot takes constant time.
+func (h *Heap[T]) Snapshot() *Heap[T] {
+	h.cow = true
+	return &Heap[T]{
+		values:  h.values[:len(h.values):len(h.values)],
+		compare: h.compare,
+		codec:   h.codec,
+		arity:   h.arity,
+		cow:     true,
+	}
+}
+
+// own makes sure that h does not share its values with a snapshot,
+// so that they can be modified.
+func (h *Heap[T]) own() {
+	if h.cow {
+		h.values = slices.Clone(h.values)
+		h.cow = false
+	}
 }
 
 // up moves the element at index i up the heap until the heap property
 // is restored.
 func (h *Heap[T]) up(i int) {
+	if h.arity != 2 {
+		h.upDary(i)
+		return
+	}
+	// Binary heaps, the common case, avoid dividing by a variable.
 	for i > 0 {
 		p := (i - 1) / 2 // parent
 		if h.compare(h.values[i], h.values[p]) >= 0 {
@@ -218,14 +454,28 @@ func (h *Heap[T]) up(i int) {
 	}
 }
 
+func (h *Heap[T]) upDary(i int) {
+	for i > 0 {
+		p := (i - 1) / h.arity // parent
+		if h.compare(h.values[i], h.values[p]) >= 0 {
+			break
+		}
+		h.swap(p, i)
+		i = p
+	}
+}
+
 // down moves the element at index i down the heap until the heap property
 // is restored. It returns true if the element moved.
 func (h *Heap[T]) down(i int) bool {
+	if h.arity != 2 {
+		return h.downDary(i)
+	}
 	n := len(h.values)
 	i0 := i
 	for {
 		lc := 2*i + 1
-		if lc >= n {
+		if lc >= n || lc < 0 { // lc < 0 after int overflow
 			break
 		}
 		child := lc // left child
@@ -241,6 +491,30 @@ func (h *Heap[T]) down(i int) bool {
 	return i > i0
 }
 
+func (h *Heap[T]) downDary(i int) bool {
+	n := len(h.values)
+	i0 := i
+	for {
+		fc := h.arity*i + 1
+		if fc >= n || fc < 0 { // fc < 0 after int overflow
+			break
+		}
+		child := fc // first child
+		end := min(fc+h.arity, n)
+		for c := fc + 1; c < end; c++ {
+			if h.compare(h.values[c], h.values[child]) < 0 {
+				child = c // smallest child so far
+			}
+		}
+		if h.compare(h.values[child], h.values[i]) >= 0 {
+			break
+		}
+		h.swap(i, child)
+		i = child
+	}
+	return i > i0
+}
+
 func (h *Heap[T]) swap(i, j int) {
 	h.values[i], h.values[j] = h.values[j], h.values[i]
 	if h.setIndex != nil {
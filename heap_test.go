@@ -2,6 +2,8 @@ package heap
 
 import (
 	"cmp"
+	"fmt"
+	"math/rand/v2"
 	"slices"
 	"testing"
 )
@@ -168,6 +170,84 @@ func TestItemAdjust(t *testing.T) {
 	}
 }
 
+func TestDeleteIndexes(t *testing.T) {
+	h := NewIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) { v.index = i })
+	items := []*intIndexed{{value: 1}, {value: 3}, {value: 2}, {value: 5}, {value: 4}}
+	h.Init(slices.Clone(items))
+
+	// Delete an element that is not last. The last element takes its place.
+	deleted, last := items[1], items[4]
+	h.Delete(deleted.index)
+	if deleted.index != -1 {
+		t.Errorf("deleted element has index %d, want -1", deleted.index)
+	}
+	if last.index != 1 {
+		t.Errorf("moved element has index %d, want 1", last.index)
+	}
+	if err := h.VerifyIndexed(func(v *intIndexed) int { return v.index }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangeMinIndexed(t *testing.T) {
+	h := NewIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) { v.index = i })
+	h.Init([]*intIndexed{{value: 1}, {value: 3}, {value: 5}})
+
+	// A new minimum that stays at the root must still get its index.
+	old := h.Min()
+	v := &intIndexed{value: 0, index: 99}
+	h.ChangeMin(v)
+	if old.index != -1 {
+		t.Errorf("old minimum has index %d, want -1", old.index)
+	}
+	if v.index != 0 {
+		t.Errorf("new minimum has index %d, want 0", v.index)
+	}
+
+	// A larger value moves down, and its index follows it.
+	v = &intIndexed{value: 4}
+	h.ChangeMin(v)
+	if err := h.VerifyIndexed(func(v *intIndexed) int { return v.index }); err != nil {
+		t.Fatal(err)
+	}
+	if v.index == 0 {
+		t.Error("new value did not move down")
+	}
+}
+
+func TestChangeMinIndexCalls(t *testing.T) {
+	type call struct{ value, index int }
+	var calls []call
+	h := NewIndexed(cmp.Compare[int], func(v, i int) { calls = append(calls, call{v, i}) })
+	h.Init([]int{1, 3, 5})
+
+	// The old minimum is removed, and the new one stays at the root.
+	calls = nil
+	h.ChangeMin(0)
+	if want := []call{{1, -1}, {0, 0}}; !slices.Equal(calls, want) {
+		t.Errorf("ChangeMin(0): index calls %v, want %v", calls, want)
+	}
+
+	// The new value moves down, past the smaller child.
+	calls = nil
+	h.ChangeMin(4)
+	if want := []call{{0, -1}, {4, 0}, {3, 0}, {4, 1}}; !slices.Equal(calls, want) {
+		t.Errorf("ChangeMin(4): index calls %v, want %v", calls, want)
+	}
+
+	// Without an index function, ChangeMin behaves the same.
+	p := New(cmp.Compare[int])
+	p.Init([]int{1, 3, 5})
+	p.ChangeMin(4)
+	if got := slices.Collect(p.Drain()); !slices.Equal(got, []int{3, 4, 5}) {
+		t.Errorf("got %v, want [3 4 5]", got)
+	}
+}
+
 func TestClear(t *testing.T) {
 	h := NewIndexed(func(a, b *intIndexed) int {
 		return cmp.Compare(a.value, b.value)
@@ -531,3 +611,326 @@ func TestInsertAll(t *testing.T) {
 		}
 	})
 }
+
+func TestDary(t *testing.T) {
+	for _, d := range []int{2, 3, 4, 8} {
+		t.Run(fmt.Sprintf("d=%d", d), func(t *testing.T) {
+			t.Run("Init", func(t *testing.T) {
+				h := NewDary(cmp.Compare[int], d)
+				data := rand.Perm(100)
+				h.Init(slices.Clone(data))
+				slices.Sort(data)
+				if got := slices.Collect(h.Drain()); !slices.Equal(got, data) {
+					t.Errorf("got %v, want %v", got, data)
+				}
+			})
+
+			t.Run("Insert and InsertAll", func(t *testing.T) {
+				h := NewDary(cmp.Compare[int], d)
+				data := rand.Perm(100)
+				for _, v := range data[:50] {
+					h.Insert(v)
+				}
+				h.InsertAll(slices.Values(data[50:]))
+				slices.Sort(data)
+				if got := slices.Collect(h.Drain()); !slices.Equal(got, data) {
+					t.Errorf("got %v, want %v", got, data)
+				}
+			})
+
+			t.Run("indexed", func(t *testing.T) {
+				h := NewDaryIndexed(func(a, b *intIndexed) int {
+					return cmp.Compare(a.value, b.value)
+				}, d, func(v *intIndexed, i int) { v.index = i })
+
+				items := make([]*intIndexed, 100)
+				for i, v := range rand.Perm(len(items)) {
+					items[i] = &intIndexed{value: v}
+				}
+				h.Init(slices.Clone(items))
+
+				checkIndexes := func() {
+					t.Helper()
+					for i, v := range h.values {
+						if v.index != i {
+							t.Fatalf("element %d has index %d, want %d", v.value, v.index, i)
+						}
+					}
+				}
+				checkIndexes()
+
+				// Delete every third item and move every fifth one.
+				var want []int
+				for i, item := range items {
+					switch {
+					case i%3 == 0:
+						h.Delete(item.index)
+						if item.index != -1 {
+							t.Errorf("deleted item has index %d, want -1", item.index)
+						}
+					case i%5 == 0:
+						item.value += 1000
+						h.Changed(item.index)
+						want = append(want, item.value)
+					default:
+						want = append(want, item.value)
+					}
+					checkIndexes()
+				}
+
+				var got []int
+				for v := range h.Drain() {
+					got = append(got, v.value)
+				}
+				slices.Sort(want)
+				if !slices.Equal(got, want) {
+					t.Errorf("got %v, want %v", got, want)
+				}
+			})
+		})
+	}
+
+	if !panics(func() { NewDary(cmp.Compare[int], 1) }) {
+		t.Error("NewDary with d=1 should panic")
+	}
+}
+
+func TestHeapMerge(t *testing.T) {
+	for _, sizes := range [][2]int{{0, 0}, {0, 10}, {10, 0}, {1000, 3}, {3, 1000}, {50, 60}} {
+		t.Run(fmt.Sprintf("%d+%d", sizes[0], sizes[1]), func(t *testing.T) {
+			h1 := New(cmp.Compare[int])
+			h2 := New(cmp.Compare[int])
+			var want []int
+			for range sizes[0] {
+				v := rand.IntN(100)
+				h1.Insert(v)
+				want = append(want, v)
+			}
+			for range sizes[1] {
+				v := rand.IntN(100)
+				h2.Insert(v)
+				want = append(want, v)
+			}
+			h1.Merge(h2)
+			if h2.Len() != 0 {
+				t.Errorf("source heap has length %d, want 0", h2.Len())
+			}
+			slices.Sort(want)
+			if got := slices.Collect(h1.Drain()); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("indexed", func(t *testing.T) {
+		cmpItems := func(a, b *intIndexed) int { return cmp.Compare(a.value, b.value) }
+		setIndex := func(v *intIndexed, i int) { v.index = i }
+		for _, sizes := range [][2]int{{1000, 3}, {3, 1000}} {
+			h1 := NewIndexed(cmpItems, setIndex)
+			h2 := NewDaryIndexed(cmpItems, 4, setIndex)
+			for range sizes[0] {
+				h1.Insert(&intIndexed{value: rand.IntN(100)})
+			}
+			for range sizes[1] {
+				h2.Insert(&intIndexed{value: rand.IntN(100)})
+			}
+			h1.Merge(h2)
+			for i, v := range h1.values {
+				if v.index != i {
+					t.Fatalf("element %d has index %d, want %d", v.value, v.index, i)
+				}
+			}
+			prev := -1
+			for v := range h1.Drain() {
+				if v.value < prev {
+					t.Fatalf("%d drained after %d", v.value, prev)
+				}
+				prev = v.value
+			}
+		}
+	})
+
+	t.Run("arity", func(t *testing.T) {
+		// The receiver keeps its arity, whether Merge sifts up the new
+		// elements or re-heapifies.
+		for _, sizes := range [][2]int{{1000, 3}, {3, 1000}} {
+			h1 := NewDary(cmp.Compare[int], 3)
+			h2 := New(cmp.Compare[int])
+			for range sizes[0] {
+				h1.Insert(rand.IntN(100))
+			}
+			for range sizes[1] {
+				h2.Insert(rand.IntN(100))
+			}
+			h1.Merge(h2)
+			if h1.arity != 3 {
+				t.Errorf("arity = %d after Merge, want 3", h1.arity)
+			}
+			if err := h1.Verify(); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		h := New(cmp.Compare[int])
+		if !panics(func() { h.Merge(h) }) {
+			t.Error("merging a heap with itself should panic")
+		}
+		if !panics(func() { h.Merge(New(func(a, b int) int { return b - a })) }) {
+			t.Error("merging heaps with different comparison functions should panic")
+		}
+		hi := NewIndexed(cmp.Compare[int], func(int, int) {})
+		if !panics(func() { h.Merge(hi) }) {
+			t.Error("merging indexed and non-indexed heaps should panic")
+		}
+	})
+}
+
+func TestSorted(t *testing.T) {
+	for _, d := range []int{2, 3, 4} {
+		h := NewDaryIndexed(func(a, b *intIndexed) int {
+			return cmp.Compare(a.value, b.value)
+		}, d, func(v *intIndexed, i int) { v.index = i })
+		var want []int
+		for range 100 {
+			v := rand.IntN(50)
+			h.Insert(&intIndexed{value: v})
+			want = append(want, v)
+		}
+		slices.Sort(want)
+		before := slices.Clone(h.values)
+
+		var got []int
+		for v := range h.Sorted() {
+			got = append(got, v.value)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("d=%d: Sorted: got %v, want %v", d, got, want)
+		}
+
+		for _, k := range []int{0, 1, 10, 100, 200} {
+			var got []int
+			for _, v := range h.Smallest(k) {
+				got = append(got, v.value)
+			}
+			if w := want[:min(k, len(want))]; !slices.Equal(got, w) {
+				t.Errorf("d=%d: Smallest(%d) = %v, want %v", d, k, got, w)
+			}
+		}
+
+		// The heap and its indexes are unchanged.
+		if !slices.Equal(h.values, before) {
+			t.Errorf("d=%d: heap changed", d)
+		}
+		for i, v := range h.values {
+			if v.index != i {
+				t.Fatalf("d=%d: element %d has index %d, want %d", d, v.value, v.index, i)
+			}
+		}
+	}
+
+	h := New(cmp.Compare[int])
+	if got := slices.Collect(h.Sorted()); len(got) != 0 {
+		t.Errorf("Sorted on empty heap: got %v", got)
+	}
+	if !panics(func() { h.Smallest(-1) }) {
+		t.Error("Smallest(-1) should panic")
+	}
+}
+
+func TestClone(t *testing.T) {
+	h := NewDary(cmp.Compare[int], 3)
+	h.Init([]int{5, 2, 8, 1, 9})
+	c := h.Clone()
+	c.Insert(0)
+	h.TakeMin()
+	if got, want := slices.Collect(c.Drain()), []int{0, 1, 2, 5, 8, 9}; !slices.Equal(got, want) {
+		t.Errorf("clone: got %v, want %v", got, want)
+	}
+	if got, want := slices.Collect(h.Drain()), []int{2, 5, 8, 9}; !slices.Equal(got, want) {
+		t.Errorf("original: got %v, want %v", got, want)
+	}
+}
+
+func TestCloneIndexed(t *testing.T) {
+	h := NewIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) { v.index = i })
+	items := []*intIndexed{{value: 5}, {value: 2}, {value: 8}, {value: 1}}
+	h.Init(slices.Clone(items))
+
+	t.Run("no index function", func(t *testing.T) {
+		c := h.Clone()
+		c.TakeMin()
+		c.Insert(&intIndexed{value: 0})
+		// The original's indexes are untouched.
+		for i, v := range h.values {
+			if v.index != i {
+				t.Errorf("element %d has index %d, want %d", v.value, v.index, i)
+			}
+		}
+		if !panics(func() { c.Delete(1) }) {
+			t.Error("Delete(1) on clone without index function should panic")
+		}
+	})
+
+	t.Run("separate index function", func(t *testing.T) {
+		cloneIndex := map[*intIndexed]int{}
+		c := h.CloneIndexed(func(v *intIndexed, i int) { cloneIndex[v] = i })
+		for i, v := range c.values {
+			if cloneIndex[v] != i {
+				t.Errorf("element %d has clone index %d, want %d", v.value, cloneIndex[v], i)
+			}
+		}
+		c.Delete(cloneIndex[items[0]])
+		if cloneIndex[items[0]] != -1 {
+			t.Errorf("deleted element has clone index %d, want -1", cloneIndex[items[0]])
+		}
+		for i, v := range h.values {
+			if v.index != i {
+				t.Errorf("element %d has index %d, want %d", v.value, v.index, i)
+			}
+		}
+		var got []int
+		for v := range c.Drain() {
+			got = append(got, v.value)
+		}
+		if want := []int{1, 2, 8}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	data := rand.Perm(50)
+	h := New(cmp.Compare[int])
+	h.Init(slices.Clone(data))
+	want := slices.Sorted(slices.Values(data))
+
+	s1 := h.Snapshot()
+	s2 := h.Snapshot()
+	if &s1.values[0] != &h.values[0] {
+		t.Error("snapshot does not share storage")
+	}
+
+	// Modify each heap differently.
+	h.Insert(-1)
+	h.Insert(-2)
+	s1.TakeMin()
+	s1.Changed(0)
+	s3 := s2.Snapshot()
+	s2.Clear()
+	s3.ChangeMin(100)
+
+	check := func(name string, h *Heap[int], want []int) {
+		t.Helper()
+		if got := slices.Collect(h.Drain()); !slices.Equal(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+	check("s1", s1, want[1:])
+	check("s2", s2, nil)
+	check("s3", s3, append(slices.Clone(want[1:]), 100))
+	check("h", h, append([]int{-2, -1}, want...))
+}
@@ -0,0 +1,340 @@
+// Package heaptest tests implementations of priority queues against the
+// semantics of [heap.Heap].
+//
+// The tests run on heaps of [*Elem], created with an index function so that
+// [Heap.Delete] and [Heap.Changed] can be exercised. They include
+// deterministic tests of each operation and a randomized, model-based test
+// that compares the implementation with a simple reference. When the
+// randomized test fails, it reports a minimized sequence of operations that
+// reproduces the failure.
+package heaptest
+
+import (
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jba/heap"
+)
+
+// Heap is the interface that an implementation must satisfy to be tested.
+// Its methods must behave like the methods of the same name on [heap.Heap].
+type Heap[T any] interface {
+	heap.IndexedQueue[T]
+	Init([]T)
+	InsertAll(iter.Seq[T])
+	ChangeMin(T)
+}
+
+// An Elem is an element of a heap under test.
+type Elem struct {
+	Value int
+	Index int // maintained by the heap's index function
+	id    int // distinguishes elements in failure reports
+}
+
+func (e *Elem) String() string {
+	return fmt.Sprintf("e%d(%d)", e.id, e.Value)
+}
+
+// Compare is the comparison function passed to a [NewFunc].
+// It orders elements by Value.
+func Compare(a, b *Elem) int {
+	switch {
+	case a.Value < b.Value:
+		return -1
+	case a.Value > b.Value:
+		return 1
+	}
+	return 0
+}
+
+// SetIndex is the index function passed to a [NewFunc].
+// It sets the Index field of its element.
+func SetIndex(e *Elem, i int) {
+	e.Index = i
+}
+
+// A NewFunc returns a new, empty heap that uses the given comparison
+// and index functions.
+type NewFunc func(compare func(a, b *Elem) int, setIndex func(*Elem, int)) Heap[*Elem]
+
+// Run runs all the tests on heaps created by newHeap.
+func Run(t *testing.T, newHeap NewFunc) {
+	t.Run("Basic", func(t *testing.T) { testBasic(t, newHeap) })
+	t.Run("Init", func(t *testing.T) { testInit(t, newHeap) })
+	t.Run("InsertAll", func(t *testing.T) { testInsertAll(t, newHeap) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newHeap) })
+	t.Run("Changed", func(t *testing.T) { testChanged(t, newHeap) })
+	t.Run("ChangeMin", func(t *testing.T) { testChangeMin(t, newHeap) })
+	t.Run("Clear", func(t *testing.T) { testClear(t, newHeap) })
+	t.Run("EarlyBreak", func(t *testing.T) { testEarlyBreak(t, newHeap) })
+	t.Run("Panics", func(t *testing.T) { testPanics(t, newHeap) })
+	t.Run("Random", func(t *testing.T) { testRandom(t, newHeap) })
+}
+
+// Fuzz runs a fuzz test on heaps created by newHeap.
+// The fuzzer's input is interpreted as a sequence of operations.
+func Fuzz(f *testing.F, newHeap NewFunc) {
+	f.Add([]byte{})
+	f.Add([]byte{opInit, 7, opTakeMin, 0, opInsert, 3, opDelete, 2, opChanged, 9, opDrain, 2})
+	f.Add([]byte{opInsertAll, 5, opChangeMin, 1, opClear, 0, opInsert, 4, opDrain, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ops := decode(data)
+		if err := run(newHeap, ops, nil); err != nil {
+			report(t, newHeap, ops, err)
+		}
+	})
+}
+
+func newElems(vals ...int) []*Elem {
+	es := make([]*Elem, len(vals))
+	for i, v := range vals {
+		es[i] = &Elem{Value: v, id: i}
+	}
+	return es
+}
+
+func values(seq iter.Seq[*Elem]) []int {
+	var vs []int
+	for e := range seq {
+		vs = append(vs, e.Value)
+	}
+	return vs
+}
+
+func testBasic(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	if h.Len() != 0 {
+		t.Errorf("new heap should have length 0, got %d", h.Len())
+	}
+	for _, e := range newElems(5, 3, 7, 1) {
+		h.Insert(e)
+	}
+	if h.Len() != 4 {
+		t.Errorf("heap should have length 4, got %d", h.Len())
+	}
+	if min := h.Min().Value; min != 1 {
+		t.Errorf("Min() = %d, want 1", min)
+	}
+	if h.Len() != 4 {
+		t.Errorf("Min() should not remove element, len = %d", h.Len())
+	}
+	for _, want := range []int{1, 3, 5, 7} {
+		if got := h.TakeMin().Value; got != want {
+			t.Errorf("TakeMin() = %d, want %d", got, want)
+		}
+	}
+	if h.Len() != 0 {
+		t.Errorf("heap should be empty, len = %d", h.Len())
+	}
+}
+
+func testInit(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	es := newElems(7, 2, 9, 1, 5)
+	h.Init(slices.Clone(es))
+	for _, e := range es {
+		if e.Index < 0 || e.Index >= len(es) {
+			t.Errorf("%s has invalid index %d", e, e.Index)
+		}
+	}
+	if got, want := values(h.Drain()), []int{1, 2, 5, 7, 9}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testInsertAll(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	es := newElems(10, 20, 5, 15, 25)
+	h.Insert(es[0])
+	h.Insert(es[1])
+	h.InsertAll(slices.Values(es[2:]))
+	for _, e := range es {
+		if e.Index < 0 || e.Index >= len(es) {
+			t.Errorf("%s has invalid index %d", e, e.Index)
+		}
+	}
+	if got, want := values(h.Drain()), []int{5, 10, 15, 20, 25}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testDelete(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	es := newElems(1, 3, 7, 5)
+	h.Init(slices.Clone(es))
+	h.Delete(es[1].Index)
+	if h.Len() != 3 {
+		t.Errorf("after Delete, heap should have 3 elements, got %d", h.Len())
+	}
+	if es[1].Index != -1 {
+		t.Errorf("deleted element has index %d, want -1", es[1].Index)
+	}
+	if got, want := values(h.Drain()), []int{1, 5, 7}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testChanged(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	es := newElems(5, 3, 7, 1, 9)
+	h.Init(slices.Clone(es))
+	es[3].Value = 8
+	h.Changed(es[3].Index)
+	if got, want := values(h.Drain()), []int{3, 5, 7, 8, 9}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testChangeMin(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	es := newElems(5, 3, 7, 6)
+	h.Init(slices.Clone(es[:3]))
+	h.ChangeMin(es[3])
+	if es[1].Index != -1 {
+		t.Errorf("replaced element has index %d, want -1", es[1].Index)
+	}
+	if es[3].Index < 0 || es[3].Index >= 3 {
+		t.Errorf("new element has invalid index %d", es[3].Index)
+	}
+	if got, want := values(h.Drain()), []int{5, 6, 7}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testClear(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	es := newElems(5, 3, 7)
+	for _, e := range es {
+		h.Insert(e)
+	}
+	h.Clear()
+	if h.Len() != 0 {
+		t.Errorf("after Clear, len should be 0, got %d", h.Len())
+	}
+	for _, e := range es {
+		if e.Index != -1 {
+			t.Errorf("after Clear, %s has index %d, want -1", e, e.Index)
+		}
+	}
+}
+
+func testEarlyBreak(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	h.Init(newElems(4, 8, 0, 6, 2, 9, 5, 1, 7, 3))
+
+	count := 0
+	for range h.All() {
+		count++
+		if count >= 3 {
+			break
+		}
+	}
+	if count != 3 || h.Len() != 10 {
+		t.Errorf("All: broke after %d iterations with len %d, want 3 and 10", count, h.Len())
+	}
+
+	var got []int
+	for e := range h.Drain() {
+		got = append(got, e.Value)
+		if len(got) == 3 {
+			break
+		}
+	}
+	if want := []int{0, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("Drain: got %v, want %v", got, want)
+	}
+	if h.Len() != 7 {
+		t.Errorf("after breaking out of Drain, len = %d, want 7", h.Len())
+	}
+	if got, want := values(h.Drain()), []int{3, 4, 5, 6, 7, 8, 9}; !slices.Equal(got, want) {
+		t.Errorf("rest of Drain: got %v, want %v", got, want)
+	}
+}
+
+func testPanics(t *testing.T, newHeap NewFunc) {
+	h := newHeap(Compare, SetIndex)
+	for name, f := range map[string]func(){
+		"Min on empty heap":       func() { h.Min() },
+		"TakeMin on empty heap":   func() { h.TakeMin() },
+		"ChangeMin on empty heap": func() { h.ChangeMin(&Elem{}) },
+	} {
+		if !panics(f) {
+			t.Errorf("%s: should panic", name)
+		}
+	}
+	h.Init(newElems(1, 2, 3))
+	for name, f := range map[string]func(){
+		"Delete(-1)":            func() { h.Delete(-1) },
+		"Delete(10)":            func() { h.Delete(10) },
+		"Changed(-1)":           func() { h.Changed(-1) },
+		"Changed(10)":           func() { h.Changed(10) },
+		"Init on nonempty heap": func() { h.Init(newElems(4)) },
+	} {
+		if !panics(f) {
+			t.Errorf("%s: should panic", name)
+		}
+	}
+}
+
+func panics(f func()) (b bool) {
+	defer func() {
+		if recover() != nil {
+			b = true
+		}
+	}()
+	f()
+	return false
+}
+
+func testRandom(t *testing.T, newHeap NewFunc) {
+	n := 200
+	if testing.Short() {
+		n = 20
+	}
+	for seed := range uint64(n) {
+		r := rand.New(rand.NewPCG(seed, 0))
+		data := make([]byte, 2*(10+r.IntN(100)))
+		for i := range data {
+			data[i] = byte(r.Uint32())
+		}
+		ops := decode(data)
+		if err := run(newHeap, ops, nil); err != nil {
+			report(t, newHeap, ops, err)
+			return
+		}
+	}
+}
+
+// report reports a failure of ops, after minimizing them.
+func report(t *testing.T, newHeap NewFunc, ops []op, err error) {
+	t.Helper()
+	min := minimize(newHeap, ops)
+	var b strings.Builder
+	merr := run(newHeap, min, func(desc string) { fmt.Fprintf(&b, "\t%s\n", desc) })
+	t.Errorf("%v\nminimized sequence of %d operations (from %d):\n%sfails with: %v",
+		err, len(min), len(ops), b.String(), merr)
+}
+
+// minimize returns a subsequence of ops that still fails,
+// from which no single operation can be removed without
+// making it pass.
+func minimize(newHeap NewFunc, ops []op) []op {
+	ops = slices.Clone(ops)
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i < len(ops); i++ {
+			shorter := slices.Delete(slices.Clone(ops), i, i+1)
+			if run(newHeap, shorter, nil) != nil {
+				ops = shorter
+				changed = true
+				i--
+			}
+		}
+	}
+	return ops
+}
@@ -0,0 +1,30 @@
+package heaptest_test
+
+import (
+	"testing"
+
+	"github.com/jba/heap"
+	"github.com/jba/heap/heaptest"
+)
+
+func newHeap(compare func(a, b *heaptest.Elem) int, setIndex func(*heaptest.Elem, int)) heaptest.Heap[*heaptest.Elem] {
+	return heap.NewIndexed(compare, setIndex)
+}
+
+func newDary(compare func(a, b *heaptest.Elem) int, setIndex func(*heaptest.Elem, int)) heaptest.Heap[*heaptest.Elem] {
+	return heap.NewDaryIndexed(compare, 4, setIndex)
+}
+
+func newStable(compare func(a, b *heaptest.Elem) int, setIndex func(*heaptest.Elem, int)) heaptest.Heap[*heaptest.Elem] {
+	return heap.NewStableIndexed(compare, heap.LIFO, setIndex)
+}
+
+func TestHeap(t *testing.T) {
+	t.Run("binary", func(t *testing.T) { heaptest.Run(t, newHeap) })
+	t.Run("4-ary", func(t *testing.T) { heaptest.Run(t, newDary) })
+	t.Run("stable", func(t *testing.T) { heaptest.Run(t, newStable) })
+}
+
+func FuzzHeap(f *testing.F) {
+	heaptest.Fuzz(f, newHeap)
+}
@@ -0,0 +1,259 @@
+package heaptest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Operation kinds.
+const (
+	opInsert = iota
+	opInsertAll
+	opInit
+	opTakeMin
+	opDelete
+	opChanged
+	opChangeMin
+	opClear
+	opDrain
+	numOps
+)
+
+var opNames = [numOps]string{
+	"Insert", "InsertAll", "Init", "TakeMin", "Delete", "Changed", "ChangeMin", "Clear", "Drain",
+}
+
+// An op is an operation on a heap. How arg is used depends on the kind.
+// Values are kept small so that there are many ties.
+type op struct {
+	kind int
+	arg  int
+}
+
+func (o op) String() string {
+	return fmt.Sprintf("%s(arg=%d)", opNames[o.kind], o.arg)
+}
+
+// decode interprets data as a sequence of operations, two bytes each.
+func decode(data []byte) []op {
+	var ops []op
+	for i := 0; i+1 < len(data); i += 2 {
+		ops = append(ops, op{kind: int(data[i]) % numOps, arg: int(data[i+1])})
+	}
+	return ops
+}
+
+// value returns a small element value derived from arg.
+func value(arg int) int {
+	return arg % 16
+}
+
+// valueList returns a list of one to eight small values derived from arg.
+func valueList(arg int) []int {
+	vs := make([]int, arg%8+1)
+	for j := range vs {
+		vs[j] = value(arg + 37*j)
+	}
+	return vs
+}
+
+// run applies ops to a new heap and to a model of it, and returns an error
+// describing the first difference between them.
+// If trace is not nil, it is called with a description of each operation
+// as it is performed.
+func run(newHeap NewFunc, ops []op, trace func(string)) (err error) {
+	h := newHeap(Compare, SetIndex)
+	var live []*Elem // the model: the elements in the heap, in insertion order
+	nextID := 0
+	newElem := func(v int) *Elem {
+		e := &Elem{Value: v, Index: -2, id: nextID}
+		nextID++
+		return e
+	}
+	remove := func(e *Elem) error {
+		i := slices.Index(live, e)
+		if i < 0 {
+			return fmt.Errorf("%s is not in the heap", e)
+		}
+		live = slices.Delete(live, i, i+1)
+		if e.Index != -1 {
+			return fmt.Errorf("removed element %s has index %d, want -1", e, e.Index)
+		}
+		return nil
+	}
+	minValue := func() int {
+		m := live[0].Value
+		for _, e := range live {
+			m = min(m, e.Value)
+		}
+		return m
+	}
+
+	var desc string
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", desc, r)
+		}
+	}()
+	for _, o := range ops {
+		var opErr error
+		switch o.kind {
+		case opInsert:
+			e := newElem(value(o.arg))
+			desc = fmt.Sprintf("Insert(%s)", e)
+			h.Insert(e)
+			live = append(live, e)
+
+		case opInsertAll, opInit:
+			var es []*Elem
+			for _, v := range valueList(o.arg) {
+				es = append(es, newElem(v))
+			}
+			if o.kind == opInsertAll {
+				desc = fmt.Sprintf("InsertAll(%v)", es)
+				h.InsertAll(slices.Values(es))
+			} else {
+				desc = fmt.Sprintf("Init(%v)", es)
+				if len(live) > 0 {
+					if !panics(func() { h.Init(es) }) {
+						opErr = errors.New("did not panic on non-empty heap")
+					}
+					break
+				}
+				h.Init(slices.Clone(es))
+			}
+			live = append(live, es...)
+
+		case opTakeMin:
+			desc = "TakeMin()"
+			if len(live) == 0 {
+				if !panics(func() { h.TakeMin() }) {
+					opErr = errors.New("did not panic on empty heap")
+				}
+				break
+			}
+			want := minValue()
+			e := h.TakeMin()
+			desc = fmt.Sprintf("TakeMin() = %s", e)
+			if e.Value != want {
+				opErr = fmt.Errorf("got value %d, want %d", e.Value, want)
+				break
+			}
+			opErr = remove(e)
+
+		case opDelete:
+			if len(live) == 0 {
+				desc = "Delete on empty heap (skipped)"
+				break
+			}
+			e := live[o.arg%len(live)]
+			desc = fmt.Sprintf("Delete(%d) of %s", e.Index, e)
+			h.Delete(e.Index)
+			opErr = remove(e)
+
+		case opChanged:
+			if len(live) == 0 {
+				desc = "Changed on empty heap (skipped)"
+				break
+			}
+			e := live[o.arg%len(live)]
+			old := e.Value
+			e.Value = value(o.arg / len(live))
+			desc = fmt.Sprintf("Changed(%d) of %s, formerly %d", e.Index, e, old)
+			h.Changed(e.Index)
+
+		case opChangeMin:
+			e := newElem(value(o.arg))
+			desc = fmt.Sprintf("ChangeMin(%s)", e)
+			if len(live) == 0 {
+				if !panics(func() { h.ChangeMin(e) }) {
+					opErr = errors.New("did not panic on empty heap")
+				}
+				break
+			}
+			old := h.Min()
+			desc = fmt.Sprintf("ChangeMin(%s) replacing %s", e, old)
+			h.ChangeMin(e)
+			if opErr = remove(old); opErr == nil {
+				live = append(live, e)
+			}
+
+		case opClear:
+			desc = "Clear()"
+			h.Clear()
+			for _, e := range live {
+				if e.Index != -1 {
+					opErr = fmt.Errorf("%s has index %d, want -1", e, e.Index)
+				}
+			}
+			live = nil
+
+		case opDrain:
+			// Take k elements and stop, or take them all if k is 0.
+			k := o.arg % 5
+			desc = fmt.Sprintf("Drain, stopping after %d", k)
+			if k == 0 {
+				desc = "Drain"
+			}
+			n := 0
+			for e := range h.Drain() {
+				if want := minValue(); e.Value != want {
+					opErr = fmt.Errorf("element %d: got %s, want value %d", n, e, want)
+					break
+				}
+				if opErr = remove(e); opErr != nil {
+					break
+				}
+				n++
+				if n == k {
+					break
+				}
+			}
+		}
+		if opErr == nil {
+			opErr = check(h, live)
+		}
+		if trace != nil {
+			trace(desc)
+		}
+		if opErr != nil {
+			return fmt.Errorf("after %s: %w", desc, opErr)
+		}
+	}
+	return nil
+}
+
+// check compares the heap with the model.
+func check(h Heap[*Elem], live []*Elem) error {
+	if h.Len() != len(live) {
+		return fmt.Errorf("Len() = %d, want %d", h.Len(), len(live))
+	}
+	seen := map[*Elem]bool{}
+	for e := range h.All() {
+		if seen[e] {
+			return fmt.Errorf("All yielded %s twice", e)
+		}
+		seen[e] = true
+	}
+	indexes := make([]bool, len(live))
+	for _, e := range live {
+		if !seen[e] {
+			return fmt.Errorf("All did not yield %s", e)
+		}
+		if e.Index < 0 || e.Index >= len(live) || indexes[e.Index] {
+			return fmt.Errorf("%s has invalid index %d", e, e.Index)
+		}
+		indexes[e.Index] = true
+	}
+	if len(live) > 0 {
+		want := live[0].Value
+		for _, e := range live {
+			want = min(want, e.Value)
+		}
+		if got := h.Min(); got.Value != want {
+			return fmt.Errorf("Min() = %s, want value %d", got, want)
+		}
+	}
+	return nil
+}
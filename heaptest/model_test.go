@@ -0,0 +1,79 @@
+package heaptest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jba/heap"
+)
+
+// buggyHeap forgets to restore the heap property in Changed
+// when an element becomes smaller.
+type buggyHeap struct {
+	*heap.Heap[*Elem]
+}
+
+func (h buggyHeap) Changed(i int) {
+	if h.Min().Value <= h.all()[i].Value {
+		h.Heap.Changed(i)
+	}
+}
+
+func (h buggyHeap) all() []*Elem {
+	es := make([]*Elem, h.Len())
+	for e := range h.All() {
+		es[e.Index] = e
+	}
+	return es
+}
+
+func newBuggy(compare func(a, b *Elem) int, setIndex func(*Elem, int)) Heap[*Elem] {
+	return buggyHeap{heap.NewIndexed(compare, setIndex)}
+}
+
+func TestMinimize(t *testing.T) {
+	// Find a failing sequence.
+	var ops []op
+	for seed := range 100 {
+		data := make([]byte, 100)
+		for i := range data {
+			data[i] = byte(seed*31 + i*i*7)
+		}
+		ops = decode(data)
+		if run(newBuggy, ops, nil) != nil {
+			break
+		}
+	}
+	if run(newBuggy, ops, nil) == nil {
+		t.Fatal("could not find a failing sequence")
+	}
+
+	min := minimize(newBuggy, ops)
+	if run(newBuggy, min, nil) == nil {
+		t.Fatal("minimized sequence does not fail")
+	}
+	if len(min) >= len(ops) {
+		t.Errorf("minimized sequence has %d operations, not fewer than %d", len(min), len(ops))
+	}
+	// Removing any single operation makes the sequence pass.
+	for i := range min {
+		shorter := append(append([]op(nil), min[:i]...), min[i+1:]...)
+		if run(newBuggy, shorter, nil) != nil {
+			t.Errorf("sequence still fails without operation %d", i)
+		}
+	}
+
+	var trace []string
+	run(newBuggy, min, func(s string) { trace = append(trace, s) })
+	if !strings.Contains(strings.Join(trace, "\n"), "Changed") {
+		t.Errorf("trace of minimized sequence does not mention Changed:\n%s", strings.Join(trace, "\n"))
+	}
+}
+
+func TestDecode(t *testing.T) {
+	ops := decode([]byte{opInsert, 3, opDrain + numOps, 2, 9})
+	want := []op{{opInsert, 3}, {opDrain, 2}}
+	if len(ops) != len(want) || ops[0] != want[0] || ops[1] != want[1] {
+		t.Errorf("got %v, want %v", ops, want)
+	}
+}
@@ -0,0 +1,214 @@
+package heap
+
+// An IndexedPQ is a min-priority queue of integer IDs in the range [0, n),
+// each with a priority. It is suited to graph algorithms whose vertices are
+// numbered densely, like Dijkstra's shortest-path algorithm.
+//
+// An IndexedPQ keeps the heap position of each ID in an array, so unlike
+// a [Heap] created with [NewIndexed], it needs no index function and its
+// elements need not be pointers. All its storage is allocated when it is
+// created.
+type IndexedPQ[P any] struct {
+	pq      []int // binary heap of IDs
+	qp      []int // qp[id] is the position of id in pq, or -1
+	prio    []P   // prio[id] is the priority of id
+	compare func(P, P) int
+}
+
+// NewIndexedPQ creates a new [IndexedPQ] for the IDs 0 through n-1,
+// whose priorities are ordered by the given comparison function.
+// See [New] for the meaning of the comparison function.
+func NewIndexedPQ[P any](n int, compare func(P, P) int) *IndexedPQ[P] {
+	q := &IndexedPQ[P]{
+		pq:      make([]int, 0, n),
+		qp:      make([]int, n),
+		prio:    make([]P, n),
+		compare: compare,
+	}
+	for i := range q.qp {
+		q.qp[i] = -1
+	}
+	return q
+}
+
+// Cap returns the number of IDs the queue can hold.
+func (q *IndexedPQ[P]) Cap() int {
+	return len(q.qp)
+}
+
+// Len returns the number of IDs in the queue.
+func (q *IndexedPQ[P]) Len() int {
+	return len(q.pq)
+}
+
+// Contains reports whether id is in the queue.
+// It panics if id is out of range.
+func (q *IndexedPQ[P]) Contains(id int) bool {
+	q.check(id)
+	return q.qp[id] >= 0
+}
+
+// Insert adds id to the queue with priority p.
+// It panics if id is out of range or already in the queue.
+func (q *IndexedPQ[P]) Insert(id int, p P) {
+	if q.Contains(id) {
+		panic("heap: Insert: ID is already in the queue")
+	}
+	q.qp[id] = len(q.pq)
+	q.pq = append(q.pq, id)
+	q.prio[id] = p
+	q.up(q.qp[id])
+}
+
+// Priority returns the priority of id.
+// It panics if id is not in the queue.
+func (q *IndexedPQ[P]) Priority(id int) P {
+	q.mustContain(id, "Priority")
+	return q.prio[id]
+}
+
+// Update changes the priority of id to p.
+// It panics if id is not in the queue.
+func (q *IndexedPQ[P]) Update(id int, p P) {
+	q.mustContain(id, "Update")
+	q.prio[id] = p
+	if i := q.qp[id]; !q.down(i) {
+		q.up(i)
+	}
+}
+
+// DecreaseKey changes the priority of id to p, which must not be greater
+// than its current priority.
+// It panics if id is not in the queue or p is greater than its priority.
+func (q *IndexedPQ[P]) DecreaseKey(id int, p P) {
+	q.mustContain(id, "DecreaseKey")
+	if q.compare(p, q.prio[id]) > 0 {
+		panic("heap: DecreaseKey: new priority is greater than current priority")
+	}
+	q.prio[id] = p
+	q.up(q.qp[id])
+}
+
+// IncreaseKey changes the priority of id to p, which must not be less
+// than its current priority.
+// It panics if id is not in the queue or p is less than its priority.
+func (q *IndexedPQ[P]) IncreaseKey(id int, p P) {
+	q.mustContain(id, "IncreaseKey")
+	if q.compare(p, q.prio[id]) < 0 {
+		panic("heap: IncreaseKey: new priority is less than current priority")
+	}
+	q.prio[id] = p
+	q.down(q.qp[id])
+}
+
+// Delete removes id from the queue.
+// It panics if id is not in the queue.
+func (q *IndexedPQ[P]) Delete(id int) {
+	q.mustContain(id, "Delete")
+	q.delete(q.qp[id])
+}
+
+// MinID returns the ID with the minimum priority without removing it.
+// It panics if the queue is empty.
+func (q *IndexedPQ[P]) MinID() int {
+	if len(q.pq) == 0 {
+		panic("heap: MinID called on empty queue")
+	}
+	return q.pq[0]
+}
+
+// TakeMin removes the ID with the minimum priority, and returns it
+// along with its priority.
+// It panics if the queue is empty.
+func (q *IndexedPQ[P]) TakeMin() (int, P) {
+	if len(q.pq) == 0 {
+		panic("heap: TakeMin called on empty queue")
+	}
+	id := q.pq[0]
+	p := q.prio[id]
+	q.delete(0)
+	return id, p
+}
+
+// Clear removes all IDs from the queue.
+func (q *IndexedPQ[P]) Clear() {
+	var zero P
+	for _, id := range q.pq {
+		q.qp[id] = -1
+		q.prio[id] = zero // allow GC
+	}
+	q.pq = q.pq[:0]
+}
+
+func (q *IndexedPQ[P]) check(id int) {
+	if id < 0 || id >= len(q.qp) {
+		panic("heap: ID out of range")
+	}
+}
+
+func (q *IndexedPQ[P]) mustContain(id int, method string) {
+	if !q.Contains(id) {
+		panic("heap: " + method + ": ID is not in the queue")
+	}
+}
+
+func (q *IndexedPQ[P]) delete(i int) {
+	id := q.pq[i]
+	n := len(q.pq) - 1
+	if n != i {
+		q.swap(i, n)
+	}
+	q.pq = q.pq[:n]
+	q.qp[id] = -1
+	var zero P
+	q.prio[id] = zero // allow GC
+	if n != i && !q.down(i) {
+		q.up(i)
+	}
+}
+
+func (q *IndexedPQ[P]) less(i, j int) bool {
+	return q.compare(q.prio[q.pq[i]], q.prio[q.pq[j]]) < 0
+}
+
+// up moves the ID at position i up the heap until the heap property
+// is restored.
+func (q *IndexedPQ[P]) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2 // parent
+		if !q.less(i, p) {
+			break
+		}
+		q.swap(p, i)
+		i = p
+	}
+}
+
+// down moves the ID at position i down the heap until the heap property
+// is restored. It returns true if the ID moved.
+func (q *IndexedPQ[P]) down(i int) bool {
+	n := len(q.pq)
+	i0 := i
+	for {
+		lc := 2*i + 1
+		if lc >= n || lc < 0 { // lc < 0 after int overflow
+			break
+		}
+		child := lc // left child
+		if rc := lc + 1; rc < n && q.less(rc, lc) {
+			child = rc // right child is smaller
+		}
+		if !q.less(child, i) {
+			break
+		}
+		q.swap(i, child)
+		i = child
+	}
+	return i > i0
+}
+
+func (q *IndexedPQ[P]) swap(i, j int) {
+	q.pq[i], q.pq[j] = q.pq[j], q.pq[i]
+	q.qp[q.pq[i]] = i
+	q.qp[q.pq[j]] = j
+}
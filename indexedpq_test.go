@@ -0,0 +1,102 @@
+package heap
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestIndexedPQ(t *testing.T) {
+	const n = 50
+	q := NewIndexedPQ(n, cmp.Compare[int])
+	if q.Cap() != n {
+		t.Errorf("Cap() = %d, want %d", q.Cap(), n)
+	}
+	model := map[int]int{} // ID to priority
+
+	checkMin := func() {
+		t.Helper()
+		if q.Len() != len(model) {
+			t.Fatalf("Len() = %d, want %d", q.Len(), len(model))
+		}
+		if q.Len() == 0 {
+			return
+		}
+		id := q.MinID()
+		for id2, p2 := range model {
+			if p2 < model[id] {
+				t.Fatalf("MinID() = %d with priority %d, but %d has priority %d", id, model[id], id2, p2)
+			}
+		}
+	}
+
+	for range 5000 {
+		id := rand.IntN(n)
+		p := rand.IntN(1000)
+		_, in := model[id]
+		if q.Contains(id) != in {
+			t.Fatalf("Contains(%d) = %t, want %t", id, !in, in)
+		}
+		switch op := rand.IntN(6); {
+		case !in:
+			q.Insert(id, p)
+			model[id] = p
+		case op == 0:
+			q.Update(id, p)
+			model[id] = p
+		case op == 1:
+			p = model[id] - rand.IntN(100)
+			q.DecreaseKey(id, p)
+			model[id] = p
+		case op == 2:
+			p = model[id] + rand.IntN(100)
+			q.IncreaseKey(id, p)
+			model[id] = p
+		case op == 3:
+			q.Delete(id)
+			delete(model, id)
+		default:
+			wantP := model[q.MinID()]
+			id, p := q.TakeMin()
+			if p != wantP || model[id] != p {
+				t.Fatalf("TakeMin() = %d, %d, want priority %d", id, p, wantP)
+			}
+			delete(model, id)
+		}
+		for id, p := range model {
+			if got := q.Priority(id); got != p {
+				t.Fatalf("Priority(%d) = %d, want %d", id, got, p)
+			}
+		}
+		checkMin()
+	}
+
+	q.Clear()
+	for id := range n {
+		if q.Contains(id) {
+			t.Fatalf("Contains(%d) after Clear", id)
+		}
+	}
+}
+
+func TestIndexedPQPanics(t *testing.T) {
+	q := NewIndexedPQ(3, cmp.Compare[int])
+	q.Insert(1, 10)
+	for name, f := range map[string]func(){
+		"Insert out of range":    func() { q.Insert(3, 0) },
+		"Insert negative":        func() { q.Insert(-1, 0) },
+		"Insert duplicate":       func() { q.Insert(1, 0) },
+		"Update missing":         func() { q.Update(0, 0) },
+		"Delete missing":         func() { q.Delete(2) },
+		"DecreaseKey increasing": func() { q.DecreaseKey(1, 11) },
+		"IncreaseKey decreasing": func() { q.IncreaseKey(1, 9) },
+	} {
+		if !panics(f) {
+			t.Errorf("%s: should panic", name)
+		}
+	}
+	q.TakeMin()
+	if !panics(func() { q.MinID() }) {
+		t.Error("MinID on empty queue should panic")
+	}
+}
@@ -0,0 +1,60 @@
+package heap
+
+import "iter"
+
+// Merge returns an iterator over the elements of seqs, each of which must be
+// sorted according to compare, in sorted order.
+// See [New] for the meaning of the comparison function.
+//
+// Merge is stable: elements that compare equal are yielded in the order of
+// the sequences they come from, and in their original order within a
+// sequence.
+//
+// The sequences are consumed incrementally, as the result is iterated.
+// Merge holds at most one element from each sequence at a time.
+// If iteration stops early, all sequences are stopped.
+func Merge[T any](compare func(T, T) int, seqs ...iter.Seq[T]) iter.Seq[T] {
+	type source struct {
+		value T
+		i     int // index in seqs, to break ties
+		next  func() (T, bool)
+	}
+
+	return func(yield func(T) bool) {
+		h := New(func(a, b source) int {
+			if c := compare(a.value, b.value); c != 0 {
+				return c
+			}
+			return a.i - b.i
+		})
+		stops := make([]func(), 0, len(seqs))
+		defer func() {
+			for _, stop := range stops {
+				stop()
+			}
+		}()
+
+		sources := make([]source, 0, len(seqs))
+		for i, seq := range seqs {
+			next, stop := iter.Pull(seq)
+			stops = append(stops, stop)
+			if v, ok := next(); ok {
+				sources = append(sources, source{v, i, next})
+			}
+		}
+		h.Init(sources)
+
+		for h.Len() > 0 {
+			s := h.Min()
+			if !yield(s.value) {
+				return
+			}
+			if v, ok := s.next(); ok {
+				s.value = v
+				h.ChangeMin(s)
+			} else {
+				h.TakeMin()
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package heap
+
+import (
+	"cmp"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	for _, test := range []struct {
+		seqs [][]int
+		want []int
+	}{
+		{nil, nil},
+		{[][]int{{}}, nil},
+		{[][]int{{1, 2, 3}}, []int{1, 2, 3}},
+		{[][]int{{1, 4, 7}, {2, 5, 8}, {3, 6, 9}}, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{[][]int{{}, {5}, {}, {1, 5, 6}}, []int{1, 5, 5, 6}},
+	} {
+		var seqs []iter.Seq[int]
+		for _, s := range test.seqs {
+			seqs = append(seqs, slices.Values(s))
+		}
+		got := slices.Collect(Merge(cmp.Compare[int], seqs...))
+		if !slices.Equal(got, test.want) {
+			t.Errorf("Merge(%v) = %v, want %v", test.seqs, got, test.want)
+		}
+	}
+}
+
+func TestMergeRandom(t *testing.T) {
+	var all []int
+	var seqs []iter.Seq[int]
+	for range 20 {
+		s := make([]int, rand.IntN(50))
+		for i := range s {
+			s[i] = rand.IntN(100)
+		}
+		slices.Sort(s)
+		all = append(all, s...)
+		seqs = append(seqs, slices.Values(s))
+	}
+	slices.Sort(all)
+	got := slices.Collect(Merge(cmp.Compare[int], seqs...))
+	if !slices.Equal(got, all) {
+		t.Errorf("got %v, want %v", got, all)
+	}
+}
+
+func TestMergeStable(t *testing.T) {
+	type pair struct{ key, src int }
+	cmpKey := func(a, b pair) int { return cmp.Compare(a.key, b.key) }
+	s0 := []pair{{1, 0}, {2, 0}, {2, 0}}
+	s1 := []pair{{1, 1}, {2, 1}}
+	s2 := []pair{{2, 2}}
+	got := slices.Collect(Merge(cmpKey, slices.Values(s0), slices.Values(s1), slices.Values(s2)))
+	want := []pair{{1, 0}, {1, 1}, {2, 0}, {2, 0}, {2, 1}, {2, 2}}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeEarlyBreak(t *testing.T) {
+	// Infinite sequences, so the inputs cannot be materialized.
+	stopped := 0
+	counter := func(start, step int) iter.Seq[int] {
+		return func(yield func(int) bool) {
+			defer func() { stopped++ }()
+			for i := start; ; i += step {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+	var got []int
+	for v := range Merge(cmp.Compare[int], counter(0, 3), counter(1, 3), counter(2, 3)) {
+		if v >= 10 {
+			break
+		}
+		got = append(got, v)
+	}
+	if want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if stopped != 3 {
+		t.Errorf("%d sequences stopped, want 3", stopped)
+	}
+}
@@ -0,0 +1,360 @@
+package heap
+
+import (
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+// A MinMaxHeap is a double-ended heap: it provides efficient access to both
+// its minimum and its maximum element.
+//
+// It is implemented as a min-max heap, a binary tree in which elements on
+// even levels are no larger than their descendants and elements on odd levels
+// are no smaller than their descendants.
+type MinMaxHeap[T any] struct {
+	values   []T
+	compare  func(T, T) int
+	setIndex func(T, int)
+}
+
+// NewMinMax creates a new [MinMaxHeap] with the given comparison function.
+// See [New] for the meaning of the comparison function.
+func NewMinMax[T any](compare func(T, T) int) *MinMaxHeap[T] {
+	return &MinMaxHeap[T]{compare: compare}
+}
+
+// NewMinMaxIndexed creates a new [MinMaxHeap] with the given comparison
+// function and index function.
+// See [NewIndexed] for the requirements on the index function.
+//
+// A MinMaxHeap created with NewMinMaxIndexed supports the [MinMaxHeap.Delete]
+// and [MinMaxHeap.Changed] methods.
+func NewMinMaxIndexed[T any](compare func(T, T) int, setIndex func(T, int)) *MinMaxHeap[T] {
+	return &MinMaxHeap[T]{compare: compare, setIndex: setIndex}
+}
+
+// Init creates a heap from the slice.
+// The heap owns the slice: the caller must not use it subsequently.
+// Init panics if the heap is not empty.
+func (h *MinMaxHeap[T]) Init(s []T) {
+	if len(h.values) != 0 {
+		panic("heap: Init: heap is not empty")
+	}
+	h.values = s
+	if h.setIndex != nil {
+		for i, e := range s {
+			h.setIndex(e, i)
+		}
+	}
+	h.heapify()
+}
+
+// Insert adds an element to the heap.
+func (h *MinMaxHeap[T]) Insert(value T) {
+	h.values = append(h.values, value)
+	if h.setIndex != nil {
+		h.setIndex(value, len(h.values)-1)
+	}
+	h.up(len(h.values) - 1)
+}
+
+// InsertAll adds all elements of the sequence to the heap,
+// re-establishing the heap property at the end.
+// It is more efficient to call InsertAll on a long sequence than
+// it is to call [MinMaxHeap.Insert] on each element of the sequence.
+func (h *MinMaxHeap[T]) InsertAll(seq iter.Seq[T]) {
+	start := len(h.values)
+	h.values = slices.AppendSeq(h.values, seq)
+	if h.setIndex != nil {
+		for i, e := range h.values[start:] {
+			h.setIndex(e, start+i)
+		}
+	}
+	h.heapify()
+}
+
+func (h *MinMaxHeap[T]) heapify() {
+	for i := len(h.values)/2 - 1; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+// Min returns the minimum element in the heap without removing it.
+// It panics if the heap is empty.
+func (h *MinMaxHeap[T]) Min() T {
+	if len(h.values) == 0 {
+		panic("heap: Min called on empty heap")
+	}
+	return h.values[0]
+}
+
+// Max returns the maximum element in the heap without removing it.
+// It panics if the heap is empty.
+func (h *MinMaxHeap[T]) Max() T {
+	if len(h.values) == 0 {
+		panic("heap: Max called on empty heap")
+	}
+	return h.values[h.maxIndex()]
+}
+
+// TakeMin removes and returns the minimum element from the heap.
+// It panics if the heap is empty.
+func (h *MinMaxHeap[T]) TakeMin() T {
+	if len(h.values) == 0 {
+		panic("heap: TakeMin called on empty heap")
+	}
+	min := h.values[0]
+	h.delete(0)
+	return min
+}
+
+// TakeMax removes and returns the maximum element from the heap.
+// It panics if the heap is empty.
+func (h *MinMaxHeap[T]) TakeMax() T {
+	if len(h.values) == 0 {
+		panic("heap: TakeMax called on empty heap")
+	}
+	i := h.maxIndex()
+	max := h.values[i]
+	h.delete(i)
+	return max
+}
+
+// maxIndex returns the index of the maximum element of a non-empty heap.
+// It is one of the root's children, or the root itself if it has none.
+func (h *MinMaxHeap[T]) maxIndex() int {
+	switch len(h.values) {
+	case 1:
+		return 0
+	case 2:
+		return 1
+	}
+	if h.compare(h.values[2], h.values[1]) > 0 {
+		return 2
+	}
+	return 1
+}
+
+// Clear removes all elements from the heap.
+func (h *MinMaxHeap[T]) Clear() {
+	if h.setIndex != nil {
+		for _, v := range h.values {
+			h.setIndex(v, -1)
+		}
+	}
+	var zero T
+	for i := range h.values {
+		h.values[i] = zero // allow GC
+	}
+	h.values = h.values[:0]
+}
+
+// Len returns the number of elements in the heap.
+func (h *MinMaxHeap[T]) Len() int {
+	return len(h.values)
+}
+
+// All returns an iterator over all elements in the heap
+// in unspecified order.
+func (h *MinMaxHeap[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range h.values {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Drain removes and returns the heap elements in sorted order,
+// from smallest to largest.
+//
+// The result is undefined if the heap is changed during iteration.
+func (h *MinMaxHeap[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for len(h.values) > 0 {
+			if !yield(h.TakeMin()) {
+				return
+			}
+		}
+	}
+}
+
+// DrainMax removes and returns the heap elements in reverse sorted order,
+// from largest to smallest.
+//
+// The result is undefined if the heap is changed during iteration.
+func (h *MinMaxHeap[T]) DrainMax() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for len(h.values) > 0 {
+			if !yield(h.TakeMax()) {
+				return
+			}
+		}
+	}
+}
+
+// Delete removes the element at index i from the heap.
+// The only reasonable values for i are 0, for the minimum element (but
+// see [MinMaxHeap.TakeMin]),
+// or an index maintained by an index function (see [NewMinMaxIndexed]).
+// If i is out of range, or it is non-zero and there is no index function,
+// Delete panics.
+func (h *MinMaxHeap[T]) Delete(i int) {
+	if i < 0 || i >= len(h.values) {
+		panic("heap: Delete: index out of range")
+	}
+	if i != 0 && h.setIndex == nil {
+		panic("heap: Delete called with non-zero index and no index function")
+	}
+	h.delete(i)
+}
+
+func (h *MinMaxHeap[T]) delete(i int) {
+	n := len(h.values) - 1
+	if n != i {
+		h.swap(i, n)
+	}
+	if h.setIndex != nil {
+		h.setIndex(h.values[n], -1)
+	}
+	var zero T
+	h.values[n] = zero // allow GC
+	h.values = h.values[:n]
+	if n != i {
+		h.fix(i)
+	}
+}
+
+// Changed restores the heap property after the element at index i has
+// been modified. The only reasonable values for i are 0, for the minimum
+// element, or an index maintained by an index function (see
+// [NewMinMaxIndexed]). If i is out of range, or it is non-zero and there is
+// no index function, Changed panics.
+func (h *MinMaxHeap[T]) Changed(i int) {
+	if i < 0 || i >= len(h.values) {
+		panic("heap: Changed: index out of range")
+	}
+	if i != 0 && h.setIndex == nil {
+		panic("heap: Changed called with non-zero index and no index function")
+	}
+	h.fix(i)
+}
+
+// isMinLevel reports whether index i is on a min level of the tree,
+// that is, at an even depth.
+func isMinLevel(i int) bool {
+	return bits.Len(uint(i+1))%2 == 1
+}
+
+// before reports whether the element at index i belongs above the element
+// at index j on a level of the given kind: it is smaller on a min level,
+// and larger on a max level.
+func (h *MinMaxHeap[T]) before(i, j int, minLevel bool) bool {
+	c := h.compare(h.values[i], h.values[j])
+	if minLevel {
+		return c < 0
+	}
+	return c > 0
+}
+
+// fix restores the heap property after the element at index i
+// has been replaced or modified.
+func (h *MinMaxHeap[T]) fix(i int) {
+	minLevel := isMinLevel(i)
+	if i > 0 {
+		// If the element is on the wrong side of its parent, it belongs
+		// on the parent's levels. The parent's old value, which is now at i,
+		// may in turn belong further down.
+		if p := (i - 1) / 2; h.before(p, i, minLevel) {
+			h.swap(i, p)
+			h.upLevels(p, !minLevel)
+			h.downLevels(i, minLevel)
+			return
+		}
+	}
+	if !h.upLevels(i, minLevel) {
+		h.downLevels(i, minLevel)
+	}
+}
+
+// up moves the newly added element at index i up the heap until the heap
+// property is restored.
+func (h *MinMaxHeap[T]) up(i int) {
+	if i == 0 {
+		return
+	}
+	minLevel := isMinLevel(i)
+	if p := (i - 1) / 2; h.before(p, i, minLevel) {
+		h.swap(i, p)
+		h.upLevels(p, !minLevel)
+	} else {
+		h.upLevels(i, minLevel)
+	}
+}
+
+// upLevels moves the element at index i up through its grandparents,
+// which are on levels of the same kind. It returns true if the element moved.
+func (h *MinMaxHeap[T]) upLevels(i int, minLevel bool) bool {
+	i0 := i
+	for i > 2 {
+		g := ((i-1)/2 - 1) / 2 // grandparent
+		if !h.before(i, g, minLevel) {
+			break
+		}
+		h.swap(i, g)
+		i = g
+	}
+	return i < i0
+}
+
+// down moves the element at index i down the heap until the heap property
+// is restored.
+func (h *MinMaxHeap[T]) down(i int) {
+	h.downLevels(i, isMinLevel(i))
+}
+
+// downLevels moves the element at index i down through its grandchildren,
+// which are on levels of the same kind, swapping it with a child when
+// that is needed to maintain the heap property.
+func (h *MinMaxHeap[T]) downLevels(i int, minLevel bool) {
+	n := len(h.values)
+	for {
+		lc := 2*i + 1
+		if lc >= n {
+			return
+		}
+		// Find the most extreme child or grandchild.
+		m := lc
+		if lc+1 < n && h.before(lc+1, m, minLevel) {
+			m = lc + 1
+		}
+		for gc := 2*lc + 1; gc < n && gc <= 2*lc+4; gc++ {
+			if h.before(gc, m, minLevel) {
+				m = gc
+			}
+		}
+		if !h.before(m, i, minLevel) {
+			return
+		}
+		h.swap(i, m)
+		if m <= lc+1 {
+			// m is a child of i. Since m was the most extreme of the
+			// children and grandchildren, the old value of i belongs at m.
+			return
+		}
+		if p := (m - 1) / 2; h.before(p, m, minLevel) {
+			h.swap(m, p)
+		}
+		i = m
+	}
+}
+
+func (h *MinMaxHeap[T]) swap(i, j int) {
+	h.values[i], h.values[j] = h.values[j], h.values[i]
+	if h.setIndex != nil {
+		h.setIndex(h.values[i], i)
+		h.setIndex(h.values[j], j)
+	}
+}
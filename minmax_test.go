@@ -0,0 +1,177 @@
+package heap
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// checkMinMax verifies the min-max heap property.
+func checkMinMax[T any](t *testing.T, h *MinMaxHeap[T]) {
+	t.Helper()
+	for i := 1; i < len(h.values); i++ {
+		// Every element must be on the correct side of each of its ancestors.
+		for a := (i - 1) / 2; ; a = (a - 1) / 2 {
+			c := h.compare(h.values[a], h.values[i])
+			if isMinLevel(a) && c > 0 || !isMinLevel(a) && c < 0 {
+				t.Fatalf("element %d (%v) is on the wrong side of ancestor %d (%v)",
+					i, h.values[i], a, h.values[a])
+			}
+			if a == 0 {
+				break
+			}
+		}
+	}
+}
+
+func TestMinMaxHeap(t *testing.T) {
+	h := NewMinMax(cmp.Compare[int])
+	for _, v := range []int{5, 3, 7, 1, 9, 4} {
+		h.Insert(v)
+		checkMinMax(t, h)
+	}
+	if got := h.Min(); got != 1 {
+		t.Errorf("Min() = %d, want 1", got)
+	}
+	if got := h.Max(); got != 9 {
+		t.Errorf("Max() = %d, want 9", got)
+	}
+	if got := h.TakeMax(); got != 9 {
+		t.Errorf("TakeMax() = %d, want 9", got)
+	}
+	if got := h.TakeMin(); got != 1 {
+		t.Errorf("TakeMin() = %d, want 1", got)
+	}
+	got := slices.Collect(h.Drain())
+	want := []int{3, 4, 5, 7}
+	if !slices.Equal(got, want) {
+		t.Errorf("Drain: got %v, want %v", got, want)
+	}
+}
+
+func TestMinMaxHeapRandom(t *testing.T) {
+	for n := range 70 {
+		data := make([]int, n)
+		for i := range data {
+			data[i] = rand.IntN(20)
+		}
+		want := slices.Sorted(slices.Values(data))
+
+		t.Run("Init", func(t *testing.T) {
+			h := NewMinMax(cmp.Compare[int])
+			h.Init(slices.Clone(data))
+			checkMinMax(t, h)
+			if got := slices.Collect(h.Drain()); !slices.Equal(got, want) {
+				t.Errorf("Drain: got %v, want %v", got, want)
+			}
+		})
+
+		t.Run("Insert", func(t *testing.T) {
+			h := NewMinMax(cmp.Compare[int])
+			for _, v := range data {
+				h.Insert(v)
+				checkMinMax(t, h)
+			}
+			got := slices.Collect(h.DrainMax())
+			slices.Reverse(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("DrainMax: got reverse of %v, want %v", got, want)
+			}
+		})
+
+		t.Run("InsertAll", func(t *testing.T) {
+			h := NewMinMax(cmp.Compare[int])
+			h.InsertAll(slices.Values(data[:n/2]))
+			h.InsertAll(slices.Values(data[n/2:]))
+			checkMinMax(t, h)
+			// Alternate between both ends.
+			lo, hi := 0, len(want)-1
+			for h.Len() > 0 {
+				if h.Len()%2 == 0 {
+					if got := h.TakeMin(); got != want[lo] {
+						t.Fatalf("TakeMin() = %d, want %d", got, want[lo])
+					}
+					lo++
+				} else {
+					if got := h.TakeMax(); got != want[hi] {
+						t.Fatalf("TakeMax() = %d, want %d", got, want[hi])
+					}
+					hi--
+				}
+				checkMinMax(t, h)
+			}
+		})
+	}
+}
+
+func TestMinMaxHeapIndexed(t *testing.T) {
+	h := NewMinMaxIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) { v.index = i })
+
+	items := make([]*intIndexed, 200)
+	for i := range items {
+		items[i] = &intIndexed{value: rand.IntN(1000)}
+	}
+	h.Init(slices.Clone(items))
+
+	checkIndexes := func() {
+		t.Helper()
+		checkMinMax(t, h)
+		for i, v := range h.values {
+			if v.index != i {
+				t.Fatalf("element %d has index %d, want %d", v.value, v.index, i)
+			}
+		}
+	}
+	checkIndexes()
+
+	var want []int
+	for i, item := range items {
+		switch i % 3 {
+		case 0:
+			h.Delete(item.index)
+			if item.index != -1 {
+				t.Errorf("deleted item has index %d, want -1", item.index)
+			}
+		case 1:
+			item.value = rand.IntN(1000)
+			h.Changed(item.index)
+			want = append(want, item.value)
+		default:
+			want = append(want, item.value)
+		}
+		checkIndexes()
+	}
+
+	var got []int
+	for v := range h.Drain() {
+		got = append(got, v.value)
+	}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMinMaxHeapPanics(t *testing.T) {
+	h := NewMinMax(cmp.Compare[int])
+	for name, f := range map[string]func(){
+		"Min":     func() { h.Min() },
+		"Max":     func() { h.Max() },
+		"TakeMin": func() { h.TakeMin() },
+		"TakeMax": func() { h.TakeMax() },
+	} {
+		if !panics(f) {
+			t.Errorf("%s on empty heap should panic", name)
+		}
+	}
+	h.Init([]int{1, 2, 3})
+	if !panics(func() { h.Delete(1) }) {
+		t.Error("Delete(1) without index function should panic")
+	}
+	if !panics(func() { h.Changed(3) }) {
+		t.Error("Changed(3) should panic")
+	}
+}
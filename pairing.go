@@ -0,0 +1,303 @@
+package heap
+
+import "iter"
+
+// A PairingHeap is a min-heap implemented as a pairing heap,
+// a tree of individually allocated nodes.
+//
+// Compared to [Heap], a PairingHeap has constant-time [PairingHeap.Insert],
+// [PairingHeap.Meld] and amortized sub-logarithmic [PairingHeap.DecreaseKey],
+// at the cost of an allocation per element and worse memory locality.
+type PairingHeap[T any] struct {
+	root    *PairingNode[T]
+	len     int
+	compare func(T, T) int
+	owner   *pairingOwner // identifies the heap to its nodes
+}
+
+// A PairingNode holds an element of a [PairingHeap].
+// It is returned by [PairingHeap.Insert] and remains valid as a handle
+// to the element until the element is removed from the heap.
+type PairingNode[T any] struct {
+	value   T
+	child   *PairingNode[T] // leftmost child
+	sibling *PairingNode[T] // next sibling to the right
+	prev    *PairingNode[T] // parent if leftmost child, else left sibling
+	owner   *pairingOwner   // owner of the heap holding the node; nil if none
+}
+
+// A pairingOwner identifies the heap that holds a node.
+// When a heap is melded into another, its owner is forwarded to the
+// other heap's owner, so that nodes need not be updated in Meld.
+type pairingOwner struct {
+	next *pairingOwner // non-nil if forwarded
+}
+
+// find returns the owner that o has been forwarded to, if any, shortening
+// the chain of forwarded owners along the way.
+func (o *pairingOwner) find() *pairingOwner {
+	for o.next != nil {
+		if o.next.next != nil {
+			o.next = o.next.next
+		}
+		o = o.next
+	}
+	return o
+}
+
+// Value returns the element held by the node.
+func (n *PairingNode[T]) Value() T {
+	return n.value
+}
+
+// NewPairing creates a new [PairingHeap] with the given comparison function.
+// See [New] for the meaning of the comparison function.
+func NewPairing[T any](compare func(T, T) int) *PairingHeap[T] {
+	return &PairingHeap[T]{compare: compare, owner: &pairingOwner{}}
+}
+
+// Insert adds an element to the heap and returns its node.
+func (h *PairingHeap[T]) Insert(value T) *PairingNode[T] {
+	n := &PairingNode[T]{value: value, owner: h.owner}
+	h.root = h.link(h.root, n)
+	h.len++
+	return n
+}
+
+// Min returns the minimum element in the heap without removing it.
+// It panics if the heap is empty.
+func (h *PairingHeap[T]) Min() T {
+	if h.root == nil {
+		panic("heap: Min called on empty heap")
+	}
+	return h.root.value
+}
+
+// TakeMin removes and returns the minimum element from the heap.
+// It panics if the heap is empty.
+func (h *PairingHeap[T]) TakeMin() T {
+	if h.root == nil {
+		panic("heap: TakeMin called on empty heap")
+	}
+	r := h.root
+	h.root = h.mergePairs(r.child)
+	h.len--
+	r.reset()
+	return r.value
+}
+
+// Clear removes all elements from the heap.
+func (h *PairingHeap[T]) Clear() {
+	// Reset every node so that stale handles are detected.
+	var stack []*PairingNode[T]
+	if h.root != nil {
+		stack = append(stack, h.root)
+	}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.child != nil {
+			stack = append(stack, n.child)
+		}
+		if n.sibling != nil {
+			stack = append(stack, n.sibling)
+		}
+		n.reset()
+	}
+	h.root = nil
+	h.len = 0
+}
+
+// Len returns the number of elements in the heap.
+func (h *PairingHeap[T]) Len() int {
+	return h.len
+}
+
+// All returns an iterator over all elements in the heap
+// in unspecified order.
+func (h *PairingHeap[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := range h.nodes() {
+			if !yield(n.value) {
+				return
+			}
+		}
+	}
+}
+
+// nodes returns an iterator over all nodes in the heap, in preorder.
+func (h *PairingHeap[T]) nodes() iter.Seq[*PairingNode[T]] {
+	return func(yield func(*PairingNode[T]) bool) {
+		var stack []*PairingNode[T]
+		if h.root != nil {
+			stack = append(stack, h.root)
+		}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(n) {
+				return
+			}
+			if n.sibling != nil {
+				stack = append(stack, n.sibling)
+			}
+			if n.child != nil {
+				stack = append(stack, n.child)
+			}
+		}
+	}
+}
+
+// Drain removes and returns the heap elements in sorted order,
+// from smallest to largest.
+//
+// The result is undefined if the heap is changed during iteration.
+func (h *PairingHeap[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for h.root != nil {
+			if !yield(h.TakeMin()) {
+				return
+			}
+		}
+	}
+}
+
+// DecreaseKey replaces the element held by n with value, which must not be
+// greater than the current element.
+// It panics if n is not in h or value is greater than n's element.
+func (h *PairingHeap[T]) DecreaseKey(n *PairingNode[T], value T) {
+	h.checkNode("DecreaseKey", n)
+	if h.compare(value, n.value) > 0 {
+		panic("heap: DecreaseKey: new value is greater than current value")
+	}
+	n.value = value
+	if n == h.root {
+		return
+	}
+	h.detach(n)
+	h.root = h.link(h.root, n)
+}
+
+// Delete removes the element held by n from the heap.
+// It panics if n is not in h.
+func (h *PairingHeap[T]) Delete(n *PairingNode[T]) {
+	h.checkNode("Delete", n)
+	if n == h.root {
+		h.TakeMin()
+		return
+	}
+	h.detach(n)
+	h.root = h.link(h.root, h.mergePairs(n.child))
+	h.len--
+	n.reset()
+}
+
+// Meld moves all the elements of other into h in constant time,
+// leaving other empty. Nodes of other remain valid and now belong to h:
+// they can no longer be used with other.
+// The two heaps must have the same comparison function.
+// Meld panics if other is h.
+func (h *PairingHeap[T]) Meld(other *PairingHeap[T]) {
+	if other == h {
+		panic("heap: Meld: cannot meld a heap with itself")
+	}
+	h.root = h.link(h.root, other.root)
+	h.len += other.len
+	other.root = nil
+	other.len = 0
+	other.owner.next = h.owner
+	other.owner = &pairingOwner{}
+}
+
+// checkNode panics if n is not in h.
+func (h *PairingHeap[T]) checkNode(method string, n *PairingNode[T]) {
+	if n.owner == nil {
+		panic("heap: " + method + ": node is not in the heap")
+	}
+	n.owner = n.owner.find()
+	if n.owner != h.owner {
+		panic("heap: " + method + ": node belongs to a different heap")
+	}
+}
+
+// link combines two trees, either of which may be nil,
+// and returns the root of the result.
+// The roots of both trees must have no siblings.
+func (h *PairingHeap[T]) link(a, b *PairingNode[T]) *PairingNode[T] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if h.compare(b.value, a.value) < 0 {
+		a, b = b, a
+	}
+	// Make b the leftmost child of a.
+	b.prev = a
+	b.sibling = a.child
+	if a.child != nil {
+		a.child.prev = b
+	}
+	a.child = b
+	a.prev = nil
+	return a
+}
+
+// mergePairs combines a list of sibling trees into one tree using the
+// standard two-pass method, and returns its root.
+func (h *PairingHeap[T]) mergePairs(first *PairingNode[T]) *PairingNode[T] {
+	if first == nil {
+		return nil
+	}
+	// First pass: link pairs from left to right, collecting the results
+	// in reverse order in a list threaded through sibling.
+	var list *PairingNode[T]
+	for first != nil {
+		a := first
+		b := a.sibling
+		if b == nil {
+			a.sibling = list
+			list = a
+			break
+		}
+		first = b.sibling
+		a.sibling, b.sibling = nil, nil
+		m := h.link(a, b)
+		m.sibling = list
+		list = m
+	}
+	// Second pass: link the results from right to left.
+	root := list
+	list = list.sibling
+	root.sibling = nil
+	for list != nil {
+		next := list.sibling
+		list.sibling = nil
+		root = h.link(root, list)
+		list = next
+	}
+	root.prev = nil
+	return root
+}
+
+// detach removes the subtree rooted at n, which is not the root,
+// from its parent.
+func (h *PairingHeap[T]) detach(n *PairingNode[T]) {
+	if n.prev.child == n {
+		n.prev.child = n.sibling
+	} else {
+		n.prev.sibling = n.sibling
+	}
+	if n.sibling != nil {
+		n.sibling.prev = n.prev
+	}
+	n.prev = nil
+	n.sibling = nil
+}
+
+// reset marks n as removed from the heap and drops its links.
+func (n *PairingNode[T]) reset() {
+	n.child, n.sibling, n.prev = nil, nil, nil
+	n.owner = nil
+}
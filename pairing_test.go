@@ -0,0 +1,177 @@
+package heap
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestPairingHeap(t *testing.T) {
+	h := NewPairing(cmp.Compare[int])
+	for _, v := range []int{5, 3, 7, 1} {
+		h.Insert(v)
+	}
+	if h.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", h.Len())
+	}
+	if got := h.Min(); got != 1 {
+		t.Errorf("Min() = %d, want 1", got)
+	}
+	got := slices.Sorted(h.All())
+	want := []int{1, 3, 5, 7}
+	if !slices.Equal(got, want) {
+		t.Errorf("All: got %v, want %v", got, want)
+	}
+	if got := slices.Collect(h.Drain()); !slices.Equal(got, want) {
+		t.Errorf("Drain: got %v, want %v", got, want)
+	}
+	if h.Len() != 0 {
+		t.Errorf("after Drain, Len() = %d, want 0", h.Len())
+	}
+}
+
+func TestPairingHeapRandom(t *testing.T) {
+	// Compare against a model: a map from node to its current value.
+	h := NewPairing(cmp.Compare[int])
+	model := map[*PairingNode[int]]int{}
+	var nodes []*PairingNode[int]
+
+	check := func() {
+		t.Helper()
+		if h.Len() != len(model) {
+			t.Fatalf("Len() = %d, want %d", h.Len(), len(model))
+		}
+		got := slices.Sorted(h.All())
+		var want []int
+		for _, v := range model {
+			want = append(want, v)
+		}
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Fatalf("All: got %v, want %v", got, want)
+		}
+		if len(want) > 0 && h.Min() != want[0] {
+			t.Fatalf("Min() = %d, want %d", h.Min(), want[0])
+		}
+	}
+
+	for range 2000 {
+		switch op := rand.IntN(10); {
+		case op < 4:
+			v := rand.IntN(1000)
+			n := h.Insert(v)
+			model[n] = v
+			nodes = append(nodes, n)
+		case op < 5 && h.Len() > 0:
+			min := h.TakeMin()
+			for n, v := range model {
+				if v == min && n.owner == nil {
+					delete(model, n)
+					break
+				}
+			}
+		case op < 8 && len(nodes) > 0:
+			n := nodes[rand.IntN(len(nodes))]
+			if _, ok := model[n]; ok {
+				v := n.Value() - rand.IntN(100)
+				h.DecreaseKey(n, v)
+				model[n] = v
+			}
+		case len(nodes) > 0:
+			i := rand.IntN(len(nodes))
+			n := nodes[i]
+			if _, ok := model[n]; ok {
+				h.Delete(n)
+				delete(model, n)
+			}
+			nodes = slices.Delete(nodes, i, i+1)
+		}
+		check()
+	}
+}
+
+func TestPairingHeapMeld(t *testing.T) {
+	h1 := NewPairing(cmp.Compare[int])
+	h2 := NewPairing(cmp.Compare[int])
+	for _, v := range []int{5, 1, 9} {
+		h1.Insert(v)
+	}
+	var n *PairingNode[int]
+	for _, v := range []int{6, 2, 8} {
+		n = h2.Insert(v)
+	}
+	h1.Meld(h2)
+	if h2.Len() != 0 {
+		t.Errorf("after Meld, other Len() = %d, want 0", h2.Len())
+	}
+	// Nodes from the other heap are still valid.
+	h1.DecreaseKey(n, 0)
+	got := slices.Collect(h1.Drain())
+	want := []int{0, 1, 2, 5, 6, 9}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPairingHeapPanics(t *testing.T) {
+	h := NewPairing(cmp.Compare[int])
+	if !panics(func() { h.Min() }) {
+		t.Error("Min on empty heap should panic")
+	}
+	if !panics(func() { h.TakeMin() }) {
+		t.Error("TakeMin on empty heap should panic")
+	}
+	n := h.Insert(5)
+	if !panics(func() { h.DecreaseKey(n, 6) }) {
+		t.Error("DecreaseKey with a greater value should panic")
+	}
+	h.Insert(3)
+	h.Clear()
+	if !panics(func() { h.Delete(n) }) {
+		t.Error("Delete of a removed node should panic")
+	}
+	if !panics(func() { h.Meld(h) }) {
+		t.Error("Meld with itself should panic")
+	}
+}
+
+func TestPairingHeapForeignNode(t *testing.T) {
+	a := NewPairing(cmp.Compare[int])
+	b := NewPairing(cmp.Compare[int])
+	a.Insert(1)
+	nb := b.Insert(2)
+	if !panics(func() { a.Delete(nb) }) {
+		t.Error("Delete of another heap's node should panic")
+	}
+	if !panics(func() { a.DecreaseKey(nb, 0) }) {
+		t.Error("DecreaseKey of another heap's node should panic")
+	}
+	if a.Len() != 1 || b.Len() != 1 {
+		t.Fatalf("Len() = %d, %d; want 1, 1", a.Len(), b.Len())
+	}
+
+	// After Meld, nodes belong to the receiving heap only, even after
+	// further melds.
+	c := NewPairing(cmp.Compare[int])
+	a.Meld(b)
+	c.Meld(a)
+	if !panics(func() { b.Delete(nb) }) {
+		t.Error("Delete with the heap melded from should panic")
+	}
+	if !panics(func() { a.Delete(nb) }) {
+		t.Error("Delete with a heap melded away should panic")
+	}
+	nb2 := b.Insert(5)
+	c.Delete(nb)
+	if got := slices.Collect(c.All()); !slices.Equal(got, []int{1}) {
+		t.Errorf("c has %v, want [1]", got)
+	}
+	if !panics(func() { c.Delete(nb2) }) {
+		t.Error("Delete of a node inserted after Meld should panic")
+	}
+	b.Delete(nb2)
+	if b.Len() != 0 {
+		t.Errorf("b.Len() = %d, want 0", b.Len())
+	}
+}
@@ -0,0 +1,109 @@
+package heap
+
+// A PriorityQueue is a collection of values identified by keys and ordered
+// by priority. The priority of a value can be changed through its key.
+//
+// A PriorityQueue is built on a [Heap] created with [NewIndexed], with
+// a map from keys to heap elements, so callers need not manage indexes.
+type PriorityQueue[K comparable, V, P any] struct {
+	h       *Heap[*pqEntry[K, V, P]]
+	entries map[K]*pqEntry[K, V, P]
+}
+
+type pqEntry[K comparable, V, P any] struct {
+	key      K
+	value    V
+	priority P
+	index    int
+}
+
+// NewPriorityQueue creates a new [PriorityQueue] whose priorities are
+// ordered by the given comparison function. The value with the smallest
+// priority is removed first.
+// See [New] for the meaning of the comparison function.
+func NewPriorityQueue[K comparable, V, P any](compare func(P, P) int) *PriorityQueue[K, V, P] {
+	return &PriorityQueue[K, V, P]{
+		h: NewIndexed(func(a, b *pqEntry[K, V, P]) int {
+			return compare(a.priority, b.priority)
+		}, func(e *pqEntry[K, V, P], i int) { e.index = i }),
+		entries: map[K]*pqEntry[K, V, P]{},
+	}
+}
+
+// Set associates value and priority with key.
+// If key is already present, its value and priority are replaced.
+func (q *PriorityQueue[K, V, P]) Set(key K, value V, priority P) {
+	if e, ok := q.entries[key]; ok {
+		e.value = value
+		e.priority = priority
+		q.h.Changed(e.index)
+		return
+	}
+	e := &pqEntry[K, V, P]{key: key, value: value, priority: priority}
+	q.entries[key] = e
+	q.h.Insert(e)
+}
+
+// Get returns the value and priority associated with key.
+// The last result reports whether key is present.
+func (q *PriorityQueue[K, V, P]) Get(key K) (V, P, bool) {
+	e, ok := q.entries[key]
+	if !ok {
+		var v V
+		var p P
+		return v, p, false
+	}
+	return e.value, e.priority, true
+}
+
+// Contains reports whether key is present.
+func (q *PriorityQueue[K, V, P]) Contains(key K) bool {
+	_, ok := q.entries[key]
+	return ok
+}
+
+// Remove removes key and its value and priority.
+// It reports whether key was present.
+func (q *PriorityQueue[K, V, P]) Remove(key K) bool {
+	e, ok := q.entries[key]
+	if !ok {
+		return false
+	}
+	q.h.Delete(e.index)
+	delete(q.entries, key)
+	return true
+}
+
+// Min returns the key, value and priority with the smallest priority,
+// without removing them.
+// It panics if the queue is empty.
+func (q *PriorityQueue[K, V, P]) Min() (K, V, P) {
+	if q.h.Len() == 0 {
+		panic("heap: Min called on empty queue")
+	}
+	e := q.h.Min()
+	return e.key, e.value, e.priority
+}
+
+// PopMin removes and returns the key, value and priority with the smallest
+// priority.
+// It panics if the queue is empty.
+func (q *PriorityQueue[K, V, P]) PopMin() (K, V, P) {
+	if q.h.Len() == 0 {
+		panic("heap: PopMin called on empty queue")
+	}
+	e := q.h.TakeMin()
+	delete(q.entries, e.key)
+	return e.key, e.value, e.priority
+}
+
+// Len returns the number of keys in the queue.
+func (q *PriorityQueue[K, V, P]) Len() int {
+	return q.h.Len()
+}
+
+// Clear removes all keys from the queue.
+func (q *PriorityQueue[K, V, P]) Clear() {
+	q.h.Clear()
+	clear(q.entries)
+}
@@ -0,0 +1,92 @@
+package heap
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	q := NewPriorityQueue[string, int](cmp.Compare[float64])
+	q.Set("a", 1, 3.0)
+	q.Set("b", 2, 1.0)
+	q.Set("c", 3, 2.0)
+
+	if v, p, ok := q.Get("a"); !ok || v != 1 || p != 3.0 {
+		t.Errorf(`Get("a") = %d, %g, %t, want 1, 3, true`, v, p, ok)
+	}
+	if _, _, ok := q.Get("z"); ok {
+		t.Error(`Get("z") found a value`)
+	}
+
+	// Update a's priority so it comes first.
+	q.Set("a", 10, 0.5)
+	if k, v, p := q.Min(); k != "a" || v != 10 || p != 0.5 {
+		t.Errorf("Min() = %q, %d, %g, want a, 10, 0.5", k, v, p)
+	}
+	if !q.Remove("b") {
+		t.Error(`Remove("b") = false`)
+	}
+	if q.Remove("b") {
+		t.Error(`second Remove("b") = true`)
+	}
+	if q.Contains("b") || !q.Contains("c") {
+		t.Error("Contains is wrong after Remove")
+	}
+
+	var keys []string
+	for q.Len() > 0 {
+		k, _, _ := q.PopMin()
+		keys = append(keys, k)
+	}
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Errorf("popped %v, want [a c]", keys)
+	}
+	if q.Contains("a") {
+		t.Error(`Contains("a") after PopMin`)
+	}
+	if !panics(func() { q.PopMin() }) {
+		t.Error("PopMin on empty queue should panic")
+	}
+}
+
+func TestPriorityQueueRandom(t *testing.T) {
+	q := NewPriorityQueue[int, string](cmp.Compare[int])
+	model := map[int]int{} // key to priority
+	for range 3000 {
+		k := rand.IntN(50)
+		switch rand.IntN(3) {
+		case 0:
+			p := rand.IntN(1000)
+			q.Set(k, "", p)
+			model[k] = p
+		case 1:
+			_, ok := model[k]
+			if got := q.Remove(k); got != ok {
+				t.Fatalf("Remove(%d) = %t, want %t", k, got, ok)
+			}
+			delete(model, k)
+		default:
+			if q.Len() == 0 {
+				continue
+			}
+			k, _, p := q.PopMin()
+			if model[k] != p {
+				t.Fatalf("PopMin() priority %d, want %d", p, model[k])
+			}
+			delete(model, k)
+			for k2, p2 := range model {
+				if p2 < p {
+					t.Fatalf("PopMin() = %d with priority %d, but %d has priority %d", k, p, k2, p2)
+				}
+			}
+		}
+		if q.Len() != len(model) {
+			t.Fatalf("Len() = %d, want %d", q.Len(), len(model))
+		}
+	}
+	q.Clear()
+	if q.Len() != 0 || q.Contains(0) {
+		t.Error("queue not empty after Clear")
+	}
+}
@@ -0,0 +1,52 @@
+package heap
+
+import "iter"
+
+// A Queue is a priority queue. Its methods behave like those of [Heap].
+//
+// Code that accepts a Queue instead of a *Heap can work with other
+// implementations: [Heap], [MinMaxHeap] and [StableHeap] satisfy Queue,
+// and IndexedQueue too.
+//
+// Several types in this package have similar methods but do not satisfy
+// Queue, because their signatures differ: [PairingHeap.Insert] returns a
+// node, [HandleHeap.Insert] returns a handle and [HandleHeap.Min] also
+// returns one, [Bounded.Insert] reports whether the element was kept and
+// Bounded has no TakeMin, and the methods of [ExternalHeap] that may do
+// I/O return errors. [PriorityQueue], [IndexedPQ] and [Sync] have
+// different methods altogether.
+type Queue[T any] interface {
+	// Insert adds an element to the queue.
+	Insert(T)
+	// Min returns the minimum element without removing it.
+	// It panics if the queue is empty.
+	Min() T
+	// TakeMin removes and returns the minimum element.
+	// It panics if the queue is empty.
+	TakeMin() T
+	// Len returns the number of elements in the queue.
+	Len() int
+	// All returns an iterator over all elements in unspecified order.
+	All() iter.Seq[T]
+	// Drain removes and returns the elements from smallest to largest.
+	Drain() iter.Seq[T]
+	// Clear removes all elements.
+	Clear()
+}
+
+// An IndexedQueue is a [Queue] whose elements can be deleted or adjusted
+// by index, as maintained by an index function (see [NewIndexed]).
+type IndexedQueue[T any] interface {
+	Queue[T]
+	// Delete removes the element at index i.
+	Delete(i int)
+	// Changed restores the queue's ordering after the element at index i
+	// has been modified.
+	Changed(i int)
+}
+
+var (
+	_ IndexedQueue[int] = (*Heap[int])(nil)
+	_ IndexedQueue[int] = (*MinMaxHeap[int])(nil)
+	_ IndexedQueue[int] = (*StableHeap[int])(nil)
+)
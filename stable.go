@@ -0,0 +1,209 @@
+package heap
+
+import (
+	"cmp"
+	"iter"
+	"math"
+	"slices"
+)
+
+// A TieOrder determines the order of elements that compare equal
+// in a [StableHeap].
+type TieOrder int
+
+const (
+	// FIFO removes equal elements in the order they were inserted.
+	FIFO TieOrder = iota
+	// LIFO removes the most recently inserted of equal elements first.
+	LIFO
+)
+
+// A StableHeap is a min-heap that removes elements that compare equal
+// in a predictable order: either first-in, first-out or last-in, first-out.
+//
+// A StableHeap records a sequence number for each element when it is
+// inserted, and uses it to break ties.
+type StableHeap[T any] struct {
+	h       *Heap[stableEntry[T]]
+	nextSeq uint64
+	maxSeq  uint64 // renumber when nextSeq reaches this; a variable for testing
+}
+
+type stableEntry[T any] struct {
+	value T
+	seq   uint64
+}
+
+// NewStable creates a new [StableHeap] with the given comparison function
+// and order for equal elements.
+// See [New] for the meaning of the comparison function.
+func NewStable[T any](compare func(T, T) int, order TieOrder) *StableHeap[T] {
+	return NewStableIndexed(compare, order, nil)
+}
+
+// NewStableIndexed creates a new [StableHeap] with the given comparison
+// function, order for equal elements, and index function.
+// See [NewIndexed] for the requirements on the index function.
+//
+// A StableHeap created with NewStableIndexed supports the
+// [StableHeap.Delete] and [StableHeap.Changed] methods.
+func NewStableIndexed[T any](compare func(T, T) int, order TieOrder, setIndex func(T, int)) *StableHeap[T] {
+	cmpEntries := func(a, b stableEntry[T]) int {
+		if c := compare(a.value, b.value); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.seq, b.seq)
+	}
+	if order == LIFO {
+		cmpEntries = func(a, b stableEntry[T]) int {
+			if c := compare(a.value, b.value); c != 0 {
+				return c
+			}
+			return cmp.Compare(b.seq, a.seq)
+		}
+	}
+	var si func(stableEntry[T], int)
+	if setIndex != nil {
+		si = func(e stableEntry[T], i int) { setIndex(e.value, i) }
+	}
+	return &StableHeap[T]{h: NewIndexed(cmpEntries, si), maxSeq: math.MaxUint64}
+}
+
+// reserve makes room for n more sequence numbers.
+func (h *StableHeap[T]) reserve(n int) {
+	if h.maxSeq-h.nextSeq < uint64(n) {
+		h.renumber()
+	}
+}
+
+// entry returns a stableEntry for v with the next sequence number.
+// The caller must have reserved the sequence number.
+func (h *StableHeap[T]) entry(v T) stableEntry[T] {
+	e := stableEntry[T]{value: v, seq: h.nextSeq}
+	h.nextSeq++
+	return e
+}
+
+// renumber assigns the sequence numbers 0 through n-1 to the n elements
+// of the heap, preserving their relative order and so the heap property.
+func (h *StableHeap[T]) renumber() {
+	vals := h.h.values
+	idx := make([]int, len(vals))
+	for i := range idx {
+		idx[i] = i
+	}
+	slices.SortFunc(idx, func(i, j int) int { return cmp.Compare(vals[i].seq, vals[j].seq) })
+	for seq, i := range idx {
+		vals[i].seq = uint64(seq)
+	}
+	h.nextSeq = uint64(len(vals))
+}
+
+// Init creates a heap from the elements of s.
+// Equal elements are ordered as if they had been inserted in slice order.
+// Init panics if the heap is not empty.
+func (h *StableHeap[T]) Init(s []T) {
+	if h.h.Len() != 0 {
+		panic("heap: Init: heap is not empty")
+	}
+	h.reserve(len(s))
+	entries := make([]stableEntry[T], len(s))
+	for i, v := range s {
+		entries[i] = h.entry(v)
+	}
+	h.h.Init(entries)
+}
+
+// Insert adds an element to the heap.
+func (h *StableHeap[T]) Insert(value T) {
+	h.reserve(1)
+	h.h.Insert(h.entry(value))
+}
+
+// InsertAll adds all elements of the sequence to the heap,
+// re-establishing the heap property at the end.
+// Equal elements are ordered as if they had been inserted in sequence order.
+func (h *StableHeap[T]) InsertAll(seq iter.Seq[T]) {
+	vals := slices.Collect(seq)
+	h.reserve(len(vals))
+	h.h.InsertAll(func(yield func(stableEntry[T]) bool) {
+		for _, v := range vals {
+			if !yield(h.entry(v)) {
+				return
+			}
+		}
+	})
+}
+
+// Min returns the minimum element in the heap without removing it.
+// It panics if the heap is empty.
+func (h *StableHeap[T]) Min() T {
+	return h.h.Min().value
+}
+
+// TakeMin removes and returns the minimum element from the heap.
+// It panics if the heap is empty.
+func (h *StableHeap[T]) TakeMin() T {
+	return h.h.TakeMin().value
+}
+
+// ChangeMin replaces the minimum value in the heap with the given value,
+// which is ordered among equal elements as if it had just been inserted.
+// It panics if the heap is empty.
+func (h *StableHeap[T]) ChangeMin(v T) {
+	if h.h.Len() == 0 {
+		panic("heap: ChangeMin called on empty heap")
+	}
+	h.reserve(1)
+	h.h.ChangeMin(h.entry(v))
+}
+
+// Clear removes all elements from the heap.
+func (h *StableHeap[T]) Clear() {
+	h.h.Clear()
+	h.nextSeq = 0
+}
+
+// Len returns the number of elements in the heap.
+func (h *StableHeap[T]) Len() int {
+	return h.h.Len()
+}
+
+// All returns an iterator over all elements in the heap
+// in unspecified order.
+func (h *StableHeap[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for e := range h.h.All() {
+			if !yield(e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Drain removes and returns the heap elements in sorted order,
+// from smallest to largest.
+//
+// The result is undefined if the heap is changed during iteration.
+func (h *StableHeap[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for e := range h.h.Drain() {
+			if !yield(e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Delete removes the element at index i from the heap.
+// See [Heap.Delete] for the valid values of i.
+func (h *StableHeap[T]) Delete(i int) {
+	h.h.Delete(i)
+}
+
+// Changed restores the heap property after the element at index i has
+// been modified. The element keeps its place among equal elements.
+// See [Heap.Changed] for the valid values of i.
+func (h *StableHeap[T]) Changed(i int) {
+	h.h.Changed(i)
+}
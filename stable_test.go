@@ -0,0 +1,143 @@
+package heap
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+type stableItem struct {
+	key, id int
+	index   int
+}
+
+func cmpStableItems(a, b *stableItem) int { return cmp.Compare(a.key, b.key) }
+
+// checkStableOrder checks that items are sorted by key, and by id within
+// a key in the direction given by order.
+func checkStableOrder(t *testing.T, items []*stableItem, order TieOrder) {
+	t.Helper()
+	for i := 1; i < len(items); i++ {
+		a, b := items[i-1], items[i]
+		if a.key > b.key ||
+			a.key == b.key && order == FIFO && a.id > b.id ||
+			a.key == b.key && order == LIFO && a.id < b.id {
+			t.Fatalf("%+v before %+v", *a, *b)
+		}
+	}
+}
+
+func TestStableHeap(t *testing.T) {
+	for _, order := range []TieOrder{FIFO, LIFO} {
+		// Items get increasing ids in insertion order.
+		id := 0
+		newItems := func(n int) []*stableItem {
+			var items []*stableItem
+			for range n {
+				items = append(items, &stableItem{key: rand.IntN(5), id: id})
+				id++
+			}
+			return items
+		}
+
+		t.Run("Insert", func(t *testing.T) {
+			h := NewStable(cmpStableItems, order)
+			for _, it := range newItems(100) {
+				h.Insert(it)
+			}
+			checkStableOrder(t, slices.Collect(h.Drain()), order)
+		})
+
+		t.Run("Init and InsertAll", func(t *testing.T) {
+			h := NewStable(cmpStableItems, order)
+			h.Init(newItems(50))
+			h.InsertAll(slices.Values(newItems(50)))
+			for _, it := range newItems(20) {
+				h.Insert(it)
+			}
+			checkStableOrder(t, slices.Collect(h.Drain()), order)
+		})
+
+		t.Run("Changed and Delete", func(t *testing.T) {
+			h := NewStableIndexed(cmpStableItems, order, func(it *stableItem, i int) { it.index = i })
+			items := newItems(100)
+			h.Init(slices.Clone(items))
+			var want int
+			for i, it := range items {
+				switch i % 4 {
+				case 0:
+					h.Delete(it.index)
+				case 1:
+					it.key = rand.IntN(5)
+					h.Changed(it.index)
+					want++
+				default:
+					want++
+				}
+			}
+			got := slices.Collect(h.Drain())
+			if len(got) != want {
+				t.Fatalf("got %d items, want %d", len(got), want)
+			}
+			checkStableOrder(t, got, order)
+		})
+
+		t.Run("ChangeMin", func(t *testing.T) {
+			h := NewStable(cmpStableItems, order)
+			h.Init(newItems(20))
+			for _, it := range newItems(100) {
+				h.ChangeMin(it)
+			}
+			checkStableOrder(t, slices.Collect(h.Drain()), order)
+		})
+
+		t.Run("overflow", func(t *testing.T) {
+			h := NewStable(cmpStableItems, order)
+			h.maxSeq = 50 // fewer than the 65 items inserted
+			h.Init(newItems(20))
+			for _, it := range newItems(20) {
+				h.Insert(it)
+				if h.Len() > 20 {
+					h.TakeMin()
+				}
+			}
+			h.InsertAll(slices.Values(newItems(25)))
+			if h.nextSeq > h.maxSeq {
+				t.Fatalf("nextSeq = %d, beyond maxSeq %d", h.nextSeq, h.maxSeq)
+			}
+			got := slices.Collect(h.Drain())
+			if len(got) != 45 {
+				t.Fatalf("got %d items, want 45", len(got))
+			}
+			checkStableOrder(t, got, order)
+		})
+	}
+}
+
+func TestStableHeapOrder(t *testing.T) {
+	type job struct {
+		priority int
+		name     string
+	}
+	cmpJobs := func(a, b job) int { return cmp.Compare(a.priority, b.priority) }
+	jobs := []job{{1, "a"}, {0, "b"}, {1, "c"}, {0, "d"}, {1, "e"}}
+
+	for _, test := range []struct {
+		order TieOrder
+		want  string
+	}{
+		{FIFO, "bdace"},
+		{LIFO, "dbeca"},
+	} {
+		h := NewStable(cmpJobs, test.order)
+		h.InsertAll(slices.Values(jobs))
+		var got string
+		for j := range h.Drain() {
+			got += j.name
+		}
+		if got != test.want {
+			t.Errorf("order %d: got %s, want %s", test.order, got, test.want)
+		}
+	}
+}
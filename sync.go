@@ -0,0 +1,160 @@
+package heap
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by [Sync] methods after [Sync.Close] has been called.
+var ErrClosed = errors.New("heap: Sync is closed")
+
+// A Sync is a priority queue that is safe for concurrent use by multiple
+// goroutines. It wraps a [Queue], such as a [Heap] or a [MinMaxHeap], with
+// a mutex, and adds blocking operations that wait for an element, or for
+// room when the Sync has a capacity.
+//
+// Closing a Sync is like closing a channel: no more elements can be inserted,
+// but the elements already present can still be taken. Once a closed Sync is
+// empty, attempts to take an element fail immediately with [ErrClosed].
+type Sync[T any] struct {
+	mu       sync.Mutex
+	h        Queue[T]
+	capacity int
+	closed   bool
+	// changed is closed whenever an element is inserted or removed,
+	// or the Sync is closed, to wake up all waiters.
+	// It is nil if there are no waiters.
+	changed chan struct{}
+}
+
+// NewSync returns a new [Sync] that wraps q. The Sync owns q: the caller
+// must not use q subsequently.
+// If capacity is positive, the Sync holds at most capacity elements and
+// [Sync.Insert] blocks when it is full. Otherwise, the Sync is unbounded.
+func NewSync[T any](q Queue[T], capacity int) *Sync[T] {
+	return &Sync[T]{h: q, capacity: capacity}
+}
+
+// Insert adds an element to s. If s is full, Insert blocks until there
+// is room or ctx is done.
+// It returns [ErrClosed] if s is closed, or ctx.Err() if ctx is done
+// before the element can be inserted.
+func (s *Sync[T]) Insert(ctx context.Context, value T) error {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return ErrClosed
+		}
+		if !s.full() {
+			s.h.Insert(value)
+			s.broadcast()
+			s.mu.Unlock()
+			return nil
+		}
+		if err := s.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// TryInsert adds an element to s if s is neither full nor closed,
+// and reports whether it did so. It never blocks.
+func (s *Sync[T]) TryInsert(value T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.full() {
+		return false
+	}
+	s.h.Insert(value)
+	s.broadcast()
+	return true
+}
+
+// TakeMin removes and returns the minimum element. If s is empty,
+// TakeMin blocks until an element is inserted, s is closed, or ctx is done.
+// It returns [ErrClosed] if s is closed and empty, or ctx.Err() if ctx
+// is done before an element is available.
+func (s *Sync[T]) TakeMin(ctx context.Context) (T, error) {
+	for {
+		s.mu.Lock()
+		if s.h.Len() > 0 {
+			v := s.h.TakeMin()
+			s.broadcast()
+			s.mu.Unlock()
+			return v, nil
+		}
+		if s.closed {
+			s.mu.Unlock()
+			var zero T
+			return zero, ErrClosed
+		}
+		if err := s.wait(ctx); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+// TryTakeMin removes and returns the minimum element if s is not empty.
+// It reports whether there was an element. It never blocks.
+func (s *Sync[T]) TryTakeMin() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.h.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	v := s.h.TakeMin()
+	s.broadcast()
+	return v, true
+}
+
+// Len returns the number of elements in s.
+func (s *Sync[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.h.Len()
+}
+
+// Close closes s. Subsequent inserts fail with [ErrClosed], and blocked
+// calls to [Sync.Insert] return ErrClosed. Calls to [Sync.TakeMin]
+// continue to return elements until s is empty.
+// Closing a closed Sync has no effect.
+func (s *Sync[T]) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.broadcast()
+	}
+}
+
+func (s *Sync[T]) full() bool {
+	return s.capacity > 0 && s.h.Len() >= s.capacity
+}
+
+// broadcast wakes up all waiters. It must be called with s.mu held.
+func (s *Sync[T]) broadcast() {
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
+
+// wait unlocks s.mu, which must be held, and waits until s changes
+// or ctx is done.
+func (s *Sync[T]) wait(ctx context.Context) error {
+	if s.changed == nil {
+		s.changed = make(chan struct{})
+	}
+	changed := s.changed
+	s.mu.Unlock()
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
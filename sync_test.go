@@ -0,0 +1,204 @@
+package heap
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	s := NewSync(New(cmp.Compare[int]), 0)
+	for _, v := range []int{5, 3, 7} {
+		if err := s.Insert(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", s.Len())
+	}
+	for _, want := range []int{3, 5} {
+		got, err := s.TakeMin(ctx)
+		if err != nil || got != want {
+			t.Errorf("TakeMin() = %d, %v, want %d, nil", got, err, want)
+		}
+	}
+	if got, ok := s.TryTakeMin(); !ok || got != 7 {
+		t.Errorf("TryTakeMin() = %d, %t, want 7, true", got, ok)
+	}
+	if _, ok := s.TryTakeMin(); ok {
+		t.Error("TryTakeMin on empty Sync succeeded")
+	}
+}
+
+func TestSyncBlocking(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("TakeMin waits for Insert", func(t *testing.T) {
+		s := NewSync(New(cmp.Compare[int]), 0)
+		done := make(chan int)
+		go func() {
+			v, err := s.TakeMin(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			done <- v
+		}()
+		s.Insert(ctx, 42)
+		if got := <-done; got != 42 {
+			t.Errorf("got %d, want 42", got)
+		}
+	})
+
+	t.Run("TakeMin canceled", func(t *testing.T) {
+		s := NewSync(New(cmp.Compare[int]), 0)
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.TakeMin(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("Insert waits for room", func(t *testing.T) {
+		s := NewSync(New(cmp.Compare[int]), 2)
+		s.Insert(ctx, 1)
+		s.Insert(ctx, 2)
+		if s.TryInsert(3) {
+			t.Fatal("TryInsert on full Sync succeeded")
+		}
+		done := make(chan error)
+		go func() { done <- s.Insert(ctx, 3) }()
+		if v, _ := s.TakeMin(ctx); v != 1 {
+			t.Errorf("TakeMin() = %d, want 1", v)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if s.Len() != 2 {
+			t.Errorf("Len() = %d, want 2", s.Len())
+		}
+	})
+
+	t.Run("Insert canceled", func(t *testing.T) {
+		s := NewSync(New(cmp.Compare[int]), 1)
+		s.Insert(ctx, 1)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := s.Insert(ctx, 2); !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want Canceled", err)
+		}
+	})
+}
+
+func TestSyncClose(t *testing.T) {
+	ctx := context.Background()
+	s := NewSync(New(cmp.Compare[int]), 1)
+	s.Insert(ctx, 1)
+
+	// A blocked Insert fails when the Sync is closed.
+	done := make(chan error)
+	go func() { done <- s.Insert(ctx, 2) }()
+	s.Close()
+	if err := <-done; !errors.Is(err, ErrClosed) {
+		t.Errorf("blocked Insert: got %v, want ErrClosed", err)
+	}
+	if s.TryInsert(3) {
+		t.Error("TryInsert on closed Sync succeeded")
+	}
+
+	// Remaining elements can still be taken.
+	if v, err := s.TakeMin(ctx); err != nil || v != 1 {
+		t.Errorf("TakeMin() = %d, %v, want 1, nil", v, err)
+	}
+	if _, err := s.TakeMin(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("TakeMin on closed, empty Sync: got %v, want ErrClosed", err)
+	}
+	s.Close() // no effect
+}
+
+func TestSyncConcurrent(t *testing.T) {
+	const (
+		producers = 8
+		consumers = 8
+		perProd   = 2000
+	)
+	ctx := context.Background()
+	s := NewSync(New(cmp.Compare[int]), 16)
+
+	var prodWG sync.WaitGroup
+	for p := range producers {
+		prodWG.Add(1)
+		go func() {
+			defer prodWG.Done()
+			for i := range perProd {
+				if err := s.Insert(ctx, p*perProd+i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	seen := make([][]int, consumers)
+	var consWG sync.WaitGroup
+	for c := range consumers {
+		consWG.Add(1)
+		go func() {
+			defer consWG.Done()
+			for {
+				v, err := s.TakeMin(ctx)
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				seen[c] = append(seen[c], v)
+			}
+		}()
+	}
+
+	prodWG.Wait()
+	s.Close()
+	consWG.Wait()
+
+	// Every element was taken exactly once.
+	got := make([]bool, producers*perProd)
+	for _, vs := range seen {
+		for _, v := range vs {
+			if got[v] {
+				t.Fatalf("%d taken twice", v)
+			}
+			got[v] = true
+		}
+	}
+	for v, ok := range got {
+		if !ok {
+			t.Fatalf("%d never taken", v)
+		}
+	}
+}
+
+func TestSyncQueue(t *testing.T) {
+	// Sync can wrap any Queue.
+	ctx := context.Background()
+	s := NewSync[int](NewMinMax(cmp.Compare[int]), 0)
+	for _, v := range []int{3, 1, 2} {
+		if err := s.Insert(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []int{1, 2, 3} {
+		got, err := s.TakeMin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("TakeMin() = %d, want %d", got, want)
+		}
+	}
+}
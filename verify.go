@@ -0,0 +1,76 @@
+package heap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verify checks that h satisfies the heap property: that no element is less
+// than its parent. If h has an index function and a function set with
+// [Heap.SetIndexOf], Verify also checks that each element's recorded index
+// is its position in the heap. It returns an error describing the first
+// violation found, or nil if there is none.
+//
+// A violation usually means that an element was modified without a
+// subsequent call to [Heap.Changed].
+//
+// When built with the heapdebug build tag, the methods of Heap that modify
+// it call Verify when they finish, and panic if it reports an error. Indexes
+// are checked only for heaps with a function set by SetIndexOf.
+func (h *Heap[T]) Verify() error {
+	if err := h.verifyOrder(); err != nil {
+		return err
+	}
+	if h.setIndex != nil && h.indexOf != nil {
+		return h.verifyIndexes(h.indexOf)
+	}
+	return nil
+}
+
+// VerifyIndexed is like [Heap.Verify], but it also checks the indexes
+// maintained by an index function (see [NewIndexed]).
+// The index function returns the index last passed to the heap's index
+// function for an element.
+func (h *Heap[T]) VerifyIndexed(index func(T) int) error {
+	if err := h.verifyOrder(); err != nil {
+		return err
+	}
+	return h.verifyIndexes(index)
+}
+
+// SetIndexOf sets a function that returns the index last passed to the
+// index function of h for an element, so that [Heap.Verify], and so every
+// operation in a heapdebug build, also checks the indexes of h.
+// It has no effect on a heap without an index function.
+func (h *Heap[T]) SetIndexOf(index func(T) int) {
+	h.indexOf = index
+}
+
+func (h *Heap[T]) verifyOrder() error {
+	for c := 1; c < len(h.values); c++ {
+		p := (c - 1) / h.arity
+		if h.compare(h.values[c], h.values[p]) < 0 {
+			return fmt.Errorf("heap: element at index %d (%v) is less than its parent at index %d (%v)",
+				c, h.values[c], p, h.values[p])
+		}
+	}
+	return nil
+}
+
+func (h *Heap[T]) verifyIndexes(index func(T) int) error {
+	for i, v := range h.values {
+		if got := index(v); got != i {
+			return fmt.Errorf("heap: element at index %d (%v) has recorded index %d", i, v, got)
+		}
+	}
+	return nil
+}
+
+// check panics if h does not satisfy the heap property, or has wrong
+// indexes (see [Heap.SetIndexOf]).
+// It is called after each operation when the heapdebug build tag is set.
+func (h *Heap[T]) check(method string) {
+	if err := h.Verify(); err != nil {
+		panic(fmt.Sprintf("heap: %s: %s", method, strings.TrimPrefix(err.Error(), "heap: ")))
+	}
+}
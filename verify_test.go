@@ -0,0 +1,61 @@
+package heap
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	h := NewIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) { v.index = i })
+	items := []*intIndexed{{value: 1}, {value: 3}, {value: 2}, {value: 5}, {value: 4}}
+	h.Init(slices.Clone(items))
+	index := func(v *intIndexed) int { return v.index }
+
+	if err := h.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.VerifyIndexed(index); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modify an element without calling Changed.
+	e := h.values[4]
+	e.value = 0
+	err := h.Verify()
+	if err == nil {
+		t.Fatal("Verify succeeded on a broken heap")
+	}
+	if want := "element at index 4 (&{0 4}) is less than its parent at index 1"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want it to contain %q", err, want)
+	}
+	h.Changed(e.index)
+	if err := h.VerifyIndexed(index); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt an index.
+	h.values[2].index = 7
+	if err := h.VerifyIndexed(index); err == nil || !strings.Contains(err.Error(), "recorded index 7") {
+		t.Errorf("got %v, want error about recorded index 7", err)
+	}
+}
+
+func TestVerifyIndexOf(t *testing.T) {
+	h := NewIndexed(func(a, b *intIndexed) int {
+		return cmp.Compare(a.value, b.value)
+	}, func(v *intIndexed, i int) { v.index = i })
+	h.Init([]*intIndexed{{value: 1}, {value: 3}, {value: 2}})
+	h.values[1].index = 5
+	// Without SetIndexOf, Verify cannot check indexes.
+	if err := h.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	h.SetIndexOf(func(v *intIndexed) int { return v.index })
+	if err := h.Verify(); err == nil || !strings.Contains(err.Error(), "recorded index 5") {
+		t.Errorf("got %v, want error about recorded index 5", err)
+	}
+}